@@ -1,14 +1,18 @@
 package config
 
 import (
+	"time"
+
 	"github.com/SwirlGit/queue-scheduler/pkg/config"
 	"github.com/SwirlGit/queue-scheduler/pkg/database/postgres"
 	"github.com/pkg/errors"
 )
 
 type Config struct {
-	QSDB postgres.Config `yaml:"qs-db"`
-	Port int
+	QSDB              postgres.Config `yaml:"qs-db"`
+	Port              int
+	MaxPayloadSize    int           `yaml:"max-payload-size"`
+	IdempotencyKeyTTL time.Duration `yaml:"idempotency-key-ttl"`
 }
 
 func InitConfig(filePath string) (Config, error) {
@@ -41,9 +41,9 @@ func main() {
 		logger.Panic("failed to init qs db", zap.Error(err))
 	}
 
-	scheduleStorage := pkgschedule.NewStorage(qsDB.Pool())
-	scheduleService := schedule.NewService(scheduleStorage)
-	scheduleHandler := schedule.NewHandler(scheduleService)
+	scheduleStorage := pkgschedule.NewStorage(logger, qsDB.Pool())
+	scheduleService := schedule.NewService(scheduleStorage, cfg.IdempotencyKeyTTL)
+	scheduleHandler := schedule.NewHandler(scheduleService, cfg.MaxPayloadSize)
 
 	server := fasthttp.NewServer([]fasthttp.RouteProvider{scheduleHandler})
 	go func() {
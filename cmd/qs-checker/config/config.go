@@ -10,7 +10,43 @@ import (
 
 type Config struct {
 	QSDB          postgres.Config `yaml:"qs-db"`
+	Port          int
+	CheckerID     string          `yaml:"checker-id"`
 	CheckDuration time.Duration   `yaml:"check-duration"`
+	Recurring     RecurringConfig `yaml:"recurring"`
+	StuckJobs     StuckJobsConfig `yaml:"stuck-jobs"`
+	Reconcile     ReconcileConfig `yaml:"reconcile"`
+	Retention     RetentionConfig `yaml:"retention"`
+	Election      ElectionConfig  `yaml:"election"`
+}
+
+type ReconcileConfig struct {
+	CheckDuration time.Duration `yaml:"check-duration"`
+}
+
+type RetentionConfig struct {
+	CheckDuration    time.Duration `yaml:"check-duration"`
+	Mode             string        `yaml:"mode"`
+	DefaultRetention time.Duration `yaml:"default-retention"`
+	DeadRetention    time.Duration `yaml:"dead-retention"`
+	BatchSize        int           `yaml:"batch-size"`
+	BatchPause       time.Duration `yaml:"batch-pause"`
+	MaxBatchesPerRun int           `yaml:"max-batches-per-run"`
+}
+
+type ElectionConfig struct {
+	CheckDuration time.Duration `yaml:"check-duration"`
+}
+
+type StuckJobsConfig struct {
+	LeaseDuration time.Duration `yaml:"lease-duration"`
+	Action        string        `yaml:"action"`
+	MaxRecoveries int           `yaml:"max-recoveries"`
+}
+
+type RecurringConfig struct {
+	CheckDuration time.Duration `yaml:"check-duration"`
+	Lookahead     time.Duration `yaml:"lookahead"`
 }
 
 func InitConfig(filePath string) (Config, error) {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -9,8 +10,14 @@ import (
 	"github.com/SwirlGit/queue-scheduler/cmd/qs-checker/config"
 	pkgschedule "github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
 	"github.com/SwirlGit/queue-scheduler/internal/qs-checker/checker"
+	"github.com/SwirlGit/queue-scheduler/internal/qs-checker/leader"
+	"github.com/SwirlGit/queue-scheduler/internal/qs-checker/reconcile"
+	"github.com/SwirlGit/queue-scheduler/internal/qs-checker/recurring"
+	"github.com/SwirlGit/queue-scheduler/internal/qs-checker/retention"
 	"github.com/SwirlGit/queue-scheduler/pkg/database/postgres"
+	"github.com/SwirlGit/queue-scheduler/pkg/fasthttp"
 	"github.com/SwirlGit/queue-scheduler/pkg/log"
+	"github.com/SwirlGit/queue-scheduler/pkg/metrics"
 	"go.uber.org/zap"
 )
 
@@ -39,11 +46,61 @@ func main() {
 		logger.Panic("failed to init qs db", zap.Error(err))
 	}
 
-	scheduleStorage := pkgschedule.NewStorage(qsDB.Pool())
-	checkerService := checker.NewService(logger, scheduleStorage, cfg.CheckDuration)
-	checkerService.Start()
+	checkerID := cfg.CheckerID
+	if checkerID == "" {
+		hostname, _ := os.Hostname()
+		checkerID = fmt.Sprintf("%s-%s-%d", appName, hostname, os.Getpid())
+	}
+	elector := leader.NewElector(logger, qsDB.Pool().Config().ConnConfig, leader.Config{
+		ID:            checkerID,
+		LockKey:       leader.CheckerLockKey,
+		CheckDuration: cfg.Election.CheckDuration,
+	})
+	elector.Start()
+	defer elector.Stop()
+
+	scheduleStorage := pkgschedule.NewStorage(logger, qsDB.Pool())
+	checkerService := checker.NewService(logger, scheduleStorage, elector, checker.Config{
+		CheckDuration:  cfg.CheckDuration,
+		LeaseDuration:  cfg.StuckJobs.LeaseDuration,
+		StuckJobAction: cfg.StuckJobs.Action,
+		MaxRecoveries:  cfg.StuckJobs.MaxRecoveries,
+	})
+	if err = checkerService.Start(); err != nil {
+		logger.Panic("failed to start checker service", zap.Error(err))
+	}
 	defer checkerService.Stop()
 
+	recurringService := recurring.NewService(logger, scheduleStorage, elector,
+		cfg.Recurring.CheckDuration, cfg.Recurring.Lookahead)
+	recurringService.Start()
+	defer recurringService.Stop()
+
+	reconcileService := reconcile.NewService(logger, scheduleStorage, elector, cfg.Reconcile.CheckDuration)
+	reconcileService.Start()
+	defer reconcileService.Stop()
+
+	retentionService := retention.NewService(logger, scheduleStorage, elector, retention.Config{
+		CheckDuration:    cfg.Retention.CheckDuration,
+		Mode:             cfg.Retention.Mode,
+		DefaultRetention: cfg.Retention.DefaultRetention,
+		DeadRetention:    cfg.Retention.DeadRetention,
+		BatchSize:        cfg.Retention.BatchSize,
+		BatchPause:       cfg.Retention.BatchPause,
+		MaxBatchesPerRun: cfg.Retention.MaxBatchesPerRun,
+	})
+	if err = retentionService.Start(); err != nil {
+		logger.Panic("failed to start retention service", zap.Error(err))
+	}
+	defer retentionService.Stop()
+
+	server := fasthttp.NewServer([]fasthttp.RouteProvider{metrics.NewHandler()})
+	go func() {
+		if err := server.Listen(fmt.Sprintf(":%d", cfg.Port)); err != nil {
+			logger.Panic("failed to start listen", zap.Error(err))
+		}
+	}()
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
@@ -52,4 +109,8 @@ func main() {
 	logger.Info("stopping...")
 	signal.Stop(stop)
 	close(stop)
+
+	if err := server.Shutdown(); err != nil {
+		logger.Panic("failed to shutdown server", zap.Error(err))
+	}
 }
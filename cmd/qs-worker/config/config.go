@@ -9,9 +9,39 @@ import (
 )
 
 type Config struct {
-	QSDB          postgres.Config `yaml:"qs-db"`
-	WorkersAmount int             `yaml:"workers-amount"`
-	CheckDuration time.Duration   `yaml:"check-duration"`
+	QSDB                 postgres.Config `yaml:"qs-db"`
+	Port                 int
+	AdminPort            int            `yaml:"admin-port"`
+	WorkerID             string         `yaml:"worker-id"`
+	WorkersAmount        int            `yaml:"workers-amount"`
+	BatchSize            int            `yaml:"batch-size"`
+	PriorityAging        *time.Duration `yaml:"priority-aging"`
+	CheckDuration        time.Duration  `yaml:"check-duration"`
+	FallbackPollDuration time.Duration  `yaml:"fallback-poll-duration"`
+	HeartBeatDuration    time.Duration  `yaml:"heart-beat-duration"`
+	JobTimeout           time.Duration  `yaml:"job-timeout"`
+	ShutdownGracePeriod  time.Duration  `yaml:"shutdown-grace-period"`
+
+	UnknownActionPolicy string          `yaml:"unknown-action-policy"`
+	UnknownActionDelay  time.Duration   `yaml:"unknown-action-delay"`
+	Retry               RetryConfig     `yaml:"retry"`
+	Autoscale           AutoscaleConfig `yaml:"autoscale"`
+}
+
+type RetryConfig struct {
+	Policy    string        `yaml:"policy"`
+	BaseDelay time.Duration `yaml:"base-delay"`
+	MaxDelay  time.Duration `yaml:"max-delay"`
+}
+
+type AutoscaleConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	MinWorkers          int           `yaml:"min-workers"`
+	MaxWorkers          int           `yaml:"max-workers"`
+	CheckDuration       time.Duration `yaml:"check-duration"`
+	ScaleUpCooldown     time.Duration `yaml:"scale-up-cooldown"`
+	ScaleDownCooldown   time.Duration `yaml:"scale-down-cooldown"`
+	MinClaimSuccessRate float64       `yaml:"min-claim-success-rate"`
 }
 
 func InitConfig(filePath string) (Config, error) {
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"context"
+
+	pkgschedule "github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/SwirlGit/queue-scheduler/internal/qs-worker/schedule"
+	"go.uber.org/zap"
+)
+
+// registerHandlers is where the actions of the worker are registered.
+func registerHandlers(logger *zap.Logger, registry *schedule.Registry) {
+	registry.HandleFunc("log", func(_ context.Context, job pkgschedule.Job) error {
+		logger.Info("log job", zap.Int64("jobID", job.ID), zap.ByteString("payload", job.Payload))
+		return nil
+	})
+}
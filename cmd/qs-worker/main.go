@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/SwirlGit/queue-scheduler/cmd/qs-worker/config"
 	pkgschedule "github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/SwirlGit/queue-scheduler/internal/qs-worker/api/v1/pool"
 	"github.com/SwirlGit/queue-scheduler/internal/qs-worker/schedule"
 	"github.com/SwirlGit/queue-scheduler/pkg/database/postgres"
+	"github.com/SwirlGit/queue-scheduler/pkg/fasthttp"
 	"github.com/SwirlGit/queue-scheduler/pkg/log"
+	"github.com/SwirlGit/queue-scheduler/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 )
 
@@ -39,13 +44,77 @@ func main() {
 		logger.Panic("failed to init qs db", zap.Error(err))
 	}
 
-	scheduleStorage := pkgschedule.NewStorage(qsDB.Pool())
-	scheduleService := schedule.NewService(logger, scheduleStorage, cfg.CheckDuration)
-	if err = scheduleService.Start(cfg.WorkersAmount); err != nil {
+	scheduleStorage := pkgschedule.NewStorage(logger, qsDB.Pool())
+	retryPolicy, err := schedule.NewRetryPolicy(cfg.Retry.Policy, cfg.Retry.BaseDelay, cfg.Retry.MaxDelay)
+	if err != nil {
+		logger.Panic("failed to init retry policy", zap.Error(err))
+	}
+
+	workerID := cfg.WorkerID
+	if workerID == "" {
+		hostname, _ := os.Hostname()
+		workerID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	registry := schedule.NewRegistry()
+	registerHandlers(logger, registry)
+	scheduleService := schedule.NewService(logger, scheduleStorage, registry, schedule.Config{
+		WorkerID:             workerID,
+		BatchSize:            cfg.BatchSize,
+		PriorityAging:        cfg.PriorityAging,
+		CheckDuration:        cfg.CheckDuration,
+		FallbackPollDuration: cfg.FallbackPollDuration,
+		HeartBeatDuration:    cfg.HeartBeatDuration,
+		UnknownActionPolicy:  cfg.UnknownActionPolicy,
+		UnknownActionDelay:   cfg.UnknownActionDelay,
+		RetryPolicy:          retryPolicy,
+		JobTimeout:           cfg.JobTimeout,
+		ShutdownGracePeriod:  cfg.ShutdownGracePeriod,
+	})
+	workersAmount := cfg.WorkersAmount
+	if cfg.Autoscale.Enabled {
+		workersAmount = cfg.Autoscale.MinWorkers
+	}
+	if err = scheduleService.Start(workersAmount); err != nil {
 		logger.Panic("failed to start schedule service", zap.Error(err))
 	}
 	defer scheduleService.Stop()
 
+	if cfg.Autoscale.Enabled {
+		autoscaler := schedule.NewAutoscaler(logger, scheduleStorage, scheduleService, schedule.AutoscalerConfig{
+			WorkerID:            workerID,
+			MinWorkers:          cfg.Autoscale.MinWorkers,
+			MaxWorkers:          cfg.Autoscale.MaxWorkers,
+			CheckDuration:       cfg.Autoscale.CheckDuration,
+			ScaleUpCooldown:     cfg.Autoscale.ScaleUpCooldown,
+			ScaleDownCooldown:   cfg.Autoscale.ScaleDownCooldown,
+			MinClaimSuccessRate: cfg.Autoscale.MinClaimSuccessRate,
+		})
+		if err = autoscaler.Start(); err != nil {
+			logger.Panic("failed to start autoscaler", zap.Error(err))
+		}
+		defer autoscaler.Stop()
+	}
+
+	poolHandler := pool.NewHandler(scheduleService)
+
+	server := fasthttp.NewServer([]fasthttp.RouteProvider{poolHandler, metrics.NewHandler()})
+	go func() {
+		if err := server.Listen(fmt.Sprintf(":%d", cfg.Port)); err != nil {
+			logger.Panic("failed to start listen", zap.Error(err))
+		}
+	}()
+
+	var adminServer *fiber.App
+	if cfg.AdminPort != 0 {
+		adminServer = fasthttp.NewServer([]fasthttp.RouteProvider{pool.NewAdminHandler(scheduleService)})
+		go func() {
+			if err := adminServer.Listen(fmt.Sprintf(":%d", cfg.AdminPort)); err != nil {
+				logger.Panic("failed to start admin listen", zap.Error(err))
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
@@ -54,4 +123,13 @@ func main() {
 	logger.Info("stopping...")
 	signal.Stop(stop)
 	close(stop)
+
+	if adminServer != nil {
+		if err := adminServer.Shutdown(); err != nil {
+			logger.Panic("failed to shutdown admin server", zap.Error(err))
+		}
+	}
+	if err := server.Shutdown(); err != nil {
+		logger.Panic("failed to shutdown server", zap.Error(err))
+	}
 }
@@ -0,0 +1,5 @@
+CREATE TYPE public.CATCH_UP_POLICY AS ENUM (
+    'skip',
+    'once',
+    'all'
+);
@@ -0,0 +1,5 @@
+CREATE TYPE public.JOB_ERROR_KIND AS ENUM (
+    'error',
+    'timeout',
+    'lease_expired'
+);
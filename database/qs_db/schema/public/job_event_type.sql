@@ -0,0 +1,11 @@
+CREATE TYPE public.JOB_EVENT_TYPE AS ENUM (
+    'created',
+    'claimed',
+    'finished',
+    'released',
+    'retried',
+    'failed',
+    'recovered',
+    'cancelled',
+    'replayed'
+);
@@ -0,0 +1,14 @@
+CREATE TABLE public.job_events
+(
+    id          BIGSERIAL PRIMARY KEY,
+    ref_job_id  BIGINT         NOT NULL,
+    type        JOB_EVENT_TYPE NOT NULL,
+    state       JOB_STATE      NOT NULL,
+    worker_id   VARCHAR,
+    lease_token BIGINT         NOT NULL,
+    error       TEXT,
+    created_at  TIMESTAMPTZ    NOT NULL DEFAULT now(),
+    CONSTRAINT fk_job_id FOREIGN KEY (ref_job_id) REFERENCES jobs (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_job_events_job_id ON public.job_events (ref_job_id, id);
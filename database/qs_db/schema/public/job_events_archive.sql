@@ -0,0 +1,14 @@
+CREATE TABLE public.job_events_archive
+(
+    id          BIGINT PRIMARY KEY,
+    ref_job_id  BIGINT         NOT NULL,
+    type        JOB_EVENT_TYPE NOT NULL,
+    state       JOB_STATE      NOT NULL,
+    worker_id   VARCHAR,
+    lease_token BIGINT         NOT NULL,
+    error       TEXT,
+    created_at  TIMESTAMPTZ    NOT NULL,
+    archived_at TIMESTAMPTZ    NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_job_events_archive_job_id ON public.job_events_archive (ref_job_id, id);
@@ -0,0 +1,16 @@
+CREATE TABLE public.job_recoveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    ref_job_id      BIGINT           NOT NULL,
+    ref_queue_id    BIGINT           NOT NULL,
+    worker_id       VARCHAR,
+    lease_token     BIGINT           NOT NULL,
+    last_heart_beat TIMESTAMPTZ,
+    action          STUCK_JOB_ACTION NOT NULL,
+    recovered_at    TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    CONSTRAINT fk_job_id FOREIGN KEY (ref_job_id) REFERENCES jobs (id) ON DELETE CASCADE,
+    CONSTRAINT fk_queue_id FOREIGN KEY (ref_queue_id) REFERENCES queues (id)
+);
+
+CREATE UNIQUE INDEX idx_job_recovery_lease ON public.job_recoveries (ref_job_id, lease_token, action);
+CREATE INDEX idx_job_recoveries_recovered_at ON public.job_recoveries (recovered_at);
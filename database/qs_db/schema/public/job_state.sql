@@ -1,5 +1,7 @@
 CREATE TYPE public.JOB_STATE AS ENUM (
     'new',
     'running',
-    'done'
+    'done',
+    'dead',
+    'cancelled'
 );
\ No newline at end of file
@@ -1,12 +1,35 @@
 CREATE TABLE public.jobs
 (
-    id              BIGSERIAL PRIMARY KEY,
-    ref_queue_id    BIGINT      NOT NULL,
-    date_time       TIMESTAMPTZ NOT NULL,
-    action          VARCHAR     NOT NULL,
-    state           JOB_STATE   NOT NULL DEFAULT 'new':: JOB_STATE,
-    last_heart_beat TIMESTAMPTZ,
-    CONSTRAINT fk_queue_id FOREIGN KEY (ref_queue_id) REFERENCES queues (id)
+    id                     BIGSERIAL PRIMARY KEY,
+    ref_queue_id           BIGINT      NOT NULL,
+    date_time              TIMESTAMPTZ NOT NULL,
+    action                 VARCHAR     NOT NULL,
+    payload                JSONB,
+    state                  JOB_STATE   NOT NULL DEFAULT 'new':: JOB_STATE,
+    last_heart_beat        TIMESTAMPTZ,
+    attempts               INT         NOT NULL DEFAULT 0,
+    max_attempts           INT         NOT NULL DEFAULT 1,
+    last_error             TEXT,
+    last_error_kind        JOB_ERROR_KIND,
+    failed_at              TIMESTAMPTZ,
+    finished_at            TIMESTAMPTZ,
+    worker_id              VARCHAR,
+    lease_token            BIGINT      NOT NULL DEFAULT 0,
+    priority               INT         NOT NULL DEFAULT 0,
+    timeout                INTERVAL,
+    ref_schedule_id        BIGINT,
+    idempotency_key        VARCHAR,
+    idempotency_expires_at TIMESTAMPTZ,
+    CONSTRAINT fk_queue_id FOREIGN KEY (ref_queue_id) REFERENCES queues (id),
+    CONSTRAINT fk_schedule_id FOREIGN KEY (ref_schedule_id) REFERENCES schedules (id) ON DELETE SET NULL
 );
 
-CREATE INDEX idx_date_time_running ON public.jobs (date_time) WHERE state = 'new'::JOB_STATE;
\ No newline at end of file
+CREATE INDEX idx_date_time_running ON public.jobs (date_time, id) WHERE state = 'new'::JOB_STATE;
+CREATE INDEX idx_jobs_queue_head ON public.jobs (ref_queue_id, date_time, id) WHERE state = 'new'::JOB_STATE;
+CREATE INDEX idx_dead_jobs ON public.jobs (ref_queue_id, failed_at) WHERE state = 'dead'::JOB_STATE;
+CREATE UNIQUE INDEX idx_schedule_run ON public.jobs (ref_schedule_id, date_time) WHERE ref_schedule_id IS NOT NULL;
+CREATE INDEX idx_jobs_queue_date_time ON public.jobs (ref_queue_id, date_time, id);
+CREATE UNIQUE INDEX idx_idempotency_key ON public.jobs (ref_queue_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX idx_jobs_finished_at ON public.jobs (finished_at) WHERE finished_at IS NOT NULL;
+CREATE INDEX idx_jobs_finished_at_backfill ON public.jobs (id)
+    WHERE finished_at IS NULL AND state IN ('done'::JOB_STATE, 'dead'::JOB_STATE, 'cancelled'::JOB_STATE);
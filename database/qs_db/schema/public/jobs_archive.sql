@@ -0,0 +1,26 @@
+CREATE TABLE public.jobs_archive
+(
+    id                     BIGINT PRIMARY KEY,
+    ref_queue_id           BIGINT      NOT NULL,
+    date_time              TIMESTAMPTZ NOT NULL,
+    action                 VARCHAR     NOT NULL,
+    payload                JSONB,
+    state                  JOB_STATE   NOT NULL,
+    last_heart_beat        TIMESTAMPTZ,
+    attempts               INT         NOT NULL,
+    max_attempts           INT         NOT NULL,
+    last_error             TEXT,
+    last_error_kind        JOB_ERROR_KIND,
+    failed_at              TIMESTAMPTZ,
+    finished_at            TIMESTAMPTZ NOT NULL,
+    worker_id              VARCHAR,
+    lease_token            BIGINT      NOT NULL,
+    priority               INT         NOT NULL,
+    timeout                INTERVAL,
+    ref_schedule_id        BIGINT,
+    idempotency_key        VARCHAR,
+    idempotency_expires_at TIMESTAMPTZ,
+    archived_at            TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_jobs_archive_queue_finished_at ON public.jobs_archive (ref_queue_id, finished_at);
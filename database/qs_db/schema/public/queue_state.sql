@@ -1,4 +1,5 @@
 CREATE TYPE public.QUEUE_STATE AS ENUM (
     'ready',
-    'busy'
+    'paused',
+    'draining'
 );
\ No newline at end of file
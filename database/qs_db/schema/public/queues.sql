@@ -1,8 +1,22 @@
 CREATE TABLE public.queues
 (
-    id       SERIAL PRIMARY KEY,
-    queue_id VARCHAR     NOT NULL,
-    state    QUEUE_STATE NOT NULL DEFAULT 'ready'::QUEUE_STATE
+    id              SERIAL PRIMARY KEY,
+    queue_id        VARCHAR     NOT NULL,
+    state           QUEUE_STATE NOT NULL DEFAULT 'ready'::QUEUE_STATE,
+    max_concurrency INT         NOT NULL DEFAULT 1,
+    running         INT         NOT NULL DEFAULT 0,
+    rate_limit      INT,
+    rate_interval   INTERVAL    NOT NULL DEFAULT '1 second'::INTERVAL,
+    rate_tokens     FLOAT8,
+    rate_updated_at TIMESTAMPTZ,
+    job_timeout     INTERVAL,
+    lease_duration  INTERVAL,
+    retention       INTERVAL,
+    CONSTRAINT chk_max_concurrency CHECK (max_concurrency > 0),
+    CONSTRAINT chk_running CHECK (running >= 0),
+    CONSTRAINT chk_rate_limit CHECK (rate_limit > 0 AND rate_interval > '0'::INTERVAL),
+    CONSTRAINT chk_lease_duration CHECK (lease_duration > '0'::INTERVAL),
+    CONSTRAINT chk_retention CHECK (retention > '0'::INTERVAL)
 );
 
 CREATE UNIQUE INDEX idx_queue_id ON public.queues (queue_id);
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE public.schedules
+(
+    id              BIGSERIAL PRIMARY KEY,
+    name            VARCHAR         NOT NULL,
+    ref_queue_id    BIGINT          NOT NULL,
+    cron_expression VARCHAR         NOT NULL,
+    timezone        VARCHAR         NOT NULL DEFAULT 'UTC',
+    action          VARCHAR         NOT NULL,
+    payload         JSONB,
+    max_attempts    INT             NOT NULL DEFAULT 1,
+    catch_up_policy CATCH_UP_POLICY NOT NULL DEFAULT 'once'::CATCH_UP_POLICY,
+    next_run_at     TIMESTAMPTZ,
+    CONSTRAINT fk_queue_id FOREIGN KEY (ref_queue_id) REFERENCES queues (id)
+);
+
+CREATE UNIQUE INDEX idx_schedule_name ON public.schedules (name);
+CREATE INDEX idx_schedule_next_run_at ON public.schedules (next_run_at);
@@ -0,0 +1,5 @@
+CREATE TYPE public.STUCK_JOB_ACTION AS ENUM (
+    'requeue',
+    'fail',
+    'alert'
+);
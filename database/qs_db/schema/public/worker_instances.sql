@@ -0,0 +1,5 @@
+CREATE TABLE public.worker_instances
+(
+    worker_id VARCHAR PRIMARY KEY,
+    seen_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
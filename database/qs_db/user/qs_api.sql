@@ -13,7 +13,14 @@ GRANT CONNECT ON DATABASE qs_db TO qs_api;
 
 GRANT USAGE ON TYPE public.QUEUE_STATE TO qs_api;
 GRANT USAGE ON SEQUENCE public.queues_id_seq TO qs_api;
-GRANT INSERT, SELECT ON TABLE public.queues TO qs_api;
+GRANT INSERT, SELECT, UPDATE ON TABLE public.queues TO qs_api;
 GRANT USAGE ON TYPE public.JOB_STATE TO qs_api;
 GRANT USAGE ON SEQUENCE public.jobs_id_seq TO qs_api;
-GRANT INSERT ON TABLE public.jobs TO qs_api;
\ No newline at end of file
+GRANT INSERT, SELECT, UPDATE ON TABLE public.jobs TO qs_api;
+GRANT USAGE ON TYPE public.CATCH_UP_POLICY TO qs_api;
+GRANT USAGE ON SEQUENCE public.schedules_id_seq TO qs_api;
+GRANT INSERT, SELECT, UPDATE, DELETE ON TABLE public.schedules TO qs_api;
+GRANT USAGE ON TYPE public.JOB_EVENT_TYPE TO qs_api;
+GRANT USAGE ON SEQUENCE public.job_events_id_seq TO qs_api;
+GRANT INSERT, SELECT ON TABLE public.job_events TO qs_api;
+GRANT USAGE ON TYPE public.JOB_ERROR_KIND TO qs_api;
@@ -16,4 +16,15 @@ GRANT USAGE ON SEQUENCE public.queues_id_seq TO qs_checker;
 GRANT SELECT, UPDATE ON TABLE public.queues TO qs_checker;
 GRANT USAGE ON TYPE public.JOB_STATE TO qs_checker;
 GRANT USAGE ON SEQUENCE public.jobs_id_seq TO qs_checker;
-GRANT SELECT, UPDATE ON TABLE public.jobs TO qs_checker;
\ No newline at end of file
+GRANT INSERT, SELECT, UPDATE, DELETE ON TABLE public.jobs TO qs_checker;
+GRANT USAGE ON TYPE public.CATCH_UP_POLICY TO qs_checker;
+GRANT SELECT, UPDATE ON TABLE public.schedules TO qs_checker;
+GRANT USAGE ON TYPE public.STUCK_JOB_ACTION TO qs_checker;
+GRANT USAGE ON SEQUENCE public.job_recoveries_id_seq TO qs_checker;
+GRANT INSERT, SELECT ON TABLE public.job_recoveries TO qs_checker;
+GRANT INSERT, SELECT ON TABLE public.jobs_archive TO qs_checker;
+GRANT USAGE ON TYPE public.JOB_EVENT_TYPE TO qs_checker;
+GRANT USAGE ON SEQUENCE public.job_events_id_seq TO qs_checker;
+GRANT INSERT, SELECT ON TABLE public.job_events TO qs_checker;
+GRANT USAGE ON TYPE public.JOB_ERROR_KIND TO qs_checker;
+GRANT INSERT ON TABLE public.job_events_archive TO qs_checker;
@@ -16,4 +16,9 @@ GRANT USAGE ON SEQUENCE public.queues_id_seq TO qs_worker;
 GRANT SELECT, UPDATE ON TABLE public.queues TO qs_worker;
 GRANT USAGE ON TYPE public.JOB_STATE TO qs_worker;
 GRANT USAGE ON SEQUENCE public.jobs_id_seq TO qs_worker;
-GRANT SELECT, UPDATE ON TABLE public.jobs TO qs_worker;
\ No newline at end of file
+GRANT SELECT, UPDATE ON TABLE public.jobs TO qs_worker;
+GRANT USAGE ON TYPE public.JOB_EVENT_TYPE TO qs_worker;
+GRANT USAGE ON SEQUENCE public.job_events_id_seq TO qs_worker;
+GRANT INSERT ON TABLE public.job_events TO qs_worker;
+GRANT USAGE ON TYPE public.JOB_ERROR_KIND TO qs_worker;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE public.worker_instances TO qs_worker;
@@ -1,20 +1,66 @@
 package schedule
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
 
 type JobState = string
 
 const (
-	JobStateNew     = JobState("new")
-	JobStateRunning = JobState("running")
-	JobStateDone    = JobState("done")
+	JobStateNew       = JobState("new")
+	JobStateRunning   = JobState("running")
+	JobStateDone      = JobState("done")
+	JobStateDead      = JobState("dead")
+	JobStateCancelled = JobState("cancelled")
+)
+
+type JobErrorKind = string
+
+const (
+	JobErrorKindError   = JobErrorKind("error")
+	JobErrorKindTimeout = JobErrorKind("timeout")
+	// JobErrorKindLeaseExpired is the failure of a job recovered by the checker after its lease has expired.
+	JobErrorKindLeaseExpired = JobErrorKind("lease_expired")
+)
+
+// DefaultMaxAttempts keeps jobs which don't ask for retries at a single attempt, as they were before retries,
+// since handlers written for it aren't necessarily safe to run twice.
+const DefaultMaxAttempts = 1
+
+// Jobs with a higher priority are taken into work first. Priorities are bounded,
+// so the time in which aging lifts any job above all fresh jobs is bounded as well.
+const (
+	MinPriority = -100
+	MaxPriority = 100
 )
 
 type Job struct {
-	ID            int64      `db:"id"`
-	DateTime      time.Time  `db:"date_time"`
-	Action        string     `db:"action"`
-	State         JobState   `db:"state"`
-	LastHeartBeat *time.Time `db:"last_heart_beat"`
-	Queue
+	ID            int64           `db:"id"`
+	DateTime      time.Time       `db:"date_time"`
+	Action        string          `db:"action"`
+	Payload       json.RawMessage `db:"payload"`
+	State         JobState        `db:"state"`
+	LastHeartBeat *time.Time      `db:"last_heart_beat"`
+	Attempts      int             `db:"attempts"`
+	MaxAttempts   int             `db:"max_attempts"`
+	LastError     *string         `db:"last_error"`
+	LastErrorKind *JobErrorKind   `db:"last_error_kind"`
+	FailedAt      *time.Time      `db:"failed_at"`
+	WorkerID      *string         `db:"worker_id"`
+	LeaseToken    int64           `db:"lease_token"`
+	Priority      int             `db:"priority"`
+	Timeout       *time.Duration  `db:"timeout"`
+
+	IdempotencyKey *string `db:"idempotency_key"`
+	// Queue columns are prefixed, e.g. "queue.queue_id", so they don't clash with job columns.
+	Queue `db:"queue"`
+}
+
+func payloadArg(payload json.RawMessage) []byte {
+	if len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		return nil
+	}
+	return payload
 }
@@ -0,0 +1,30 @@
+package schedule
+
+import "time"
+
+type JobEventType = string
+
+const (
+	JobEventTypeCreated  = JobEventType("created")
+	JobEventTypeClaimed  = JobEventType("claimed")
+	JobEventTypeFinished = JobEventType("finished")
+	// JobEventTypeReleased is recorded when a worker hands a claimed job back without running it to the end.
+	JobEventTypeReleased = JobEventType("released")
+	JobEventTypeRetried  = JobEventType("retried")
+	JobEventTypeFailed   = JobEventType("failed")
+	// JobEventTypeRecovered is recorded when the checker requeues a stuck job.
+	JobEventTypeRecovered = JobEventType("recovered")
+	JobEventTypeCancelled = JobEventType("cancelled")
+	JobEventTypeReplayed  = JobEventType("replayed")
+)
+
+type JobEvent struct {
+	ID         int64        `db:"id"`
+	JobID      int64        `db:"ref_job_id"`
+	Type       JobEventType `db:"type"`
+	State      JobState     `db:"state"`
+	WorkerID   *string      `db:"worker_id"`
+	LeaseToken int64        `db:"lease_token"`
+	Error      *string      `db:"error"`
+	CreatedAt  time.Time    `db:"created_at"`
+}
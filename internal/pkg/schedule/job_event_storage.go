@@ -0,0 +1,37 @@
+package schedule
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/pkg/errors"
+)
+
+// jobEventColumns are the columns of job_events filled from the job, the type and the error
+// of the event are added by every query.
+const jobEventColumns = `ref_job_id, state, worker_id, lease_token`
+
+// insertJobEventQuery records the current state of the job, so it must run after the transition
+// within the same transaction.
+const insertJobEventQuery = `
+	INSERT INTO job_events (` + jobEventColumns + `, type, error)
+	SELECT id, state, worker_id, lease_token, $2, $3 FROM jobs WHERE id = $1`
+
+func insertJobEvent(ctx context.Context, e execer, jobID int64, eventType JobEventType, reason *string) error {
+	_, err := e.Exec(ctx, insertJobEventQuery, jobID, eventType, reason)
+	return errors.Wrap(err, "insert job event")
+}
+
+const getJobEventsQuery = `
+	SELECT id, ref_job_id, type, state, worker_id, lease_token, error, created_at
+	FROM job_events
+	WHERE ref_job_id = $1
+	ORDER BY id`
+
+func (s *Storage) GetJobEvents(ctx context.Context, jobID int64) ([]JobEvent, error) {
+	var events []JobEvent
+	if err := pgxscan.Select(ctx, s.pool, &events, getJobEventsQuery, jobID); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return events, nil
+}
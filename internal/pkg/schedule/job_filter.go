@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type JobsSortField = string
+
+const (
+	JobsSortFieldID       = JobsSortField("id")
+	JobsSortFieldDateTime = JobsSortField("date_time")
+)
+
+const selectJobsQuery = `
+	SELECT j.id, j.date_time, j.action, j.payload, j.state, j.last_heart_beat,
+		j.attempts, j.max_attempts, j.last_error, j.last_error_kind, j.failed_at, j.worker_id, j.lease_token,
+		j.priority, j.timeout, j.idempotency_key, j.ref_queue_id AS "queue.id", q.queue_id AS "queue.queue_id"
+	FROM jobs AS j
+	INNER JOIN queues AS q
+		ON j.ref_queue_id = q.id`
+
+// JobsFilter describes a page of jobs. The page starts right after the job identified
+// by AfterID and AfterDateTime in the requested sort order.
+type JobsFilter struct {
+	QueueID string
+	States  []JobState
+	From    *time.Time
+	To      *time.Time
+
+	SortBy JobsSortField
+	Desc   bool
+
+	AfterID       int64
+	AfterDateTime *time.Time
+	Limit         int
+}
+
+func (f *JobsFilter) query() (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.QueueID != "" {
+		conditions = append(conditions, "q.queue_id = "+arg(f.QueueID))
+	}
+	if len(f.States) > 0 {
+		conditions = append(conditions, "j.state::TEXT = ANY("+arg(f.States)+"::TEXT[])")
+	}
+	if f.From != nil {
+		conditions = append(conditions, "j.date_time >= "+arg(*f.From))
+	}
+	if f.To != nil {
+		conditions = append(conditions, "j.date_time < "+arg(*f.To))
+	}
+
+	operator, direction := ">", "ASC"
+	if f.Desc {
+		operator, direction = "<", "DESC"
+	}
+	orderBy := fmt.Sprintf("j.id %s", direction)
+	if f.SortBy == JobsSortFieldDateTime {
+		orderBy = fmt.Sprintf("j.date_time %s, j.id %s", direction, direction)
+		if f.AfterDateTime != nil {
+			conditions = append(conditions, fmt.Sprintf("(j.date_time, j.id) %s (%s, %s)",
+				operator, arg(*f.AfterDateTime), arg(f.AfterID)))
+		}
+	} else if f.AfterID != 0 {
+		conditions = append(conditions, fmt.Sprintf("j.id %s %s", operator, arg(f.AfterID)))
+	}
+
+	var query strings.Builder
+	query.WriteString(selectJobsQuery)
+	if len(conditions) > 0 {
+		query.WriteString("\n\tWHERE ")
+		query.WriteString(strings.Join(conditions, " AND "))
+	}
+	query.WriteString("\n\tORDER BY ")
+	query.WriteString(orderBy)
+	query.WriteString("\n\tLIMIT ")
+	query.WriteString(arg(f.Limit))
+	return query.String(), args
+}
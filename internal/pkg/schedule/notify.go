@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// JobsChannel is notified whenever a job may have become available for claiming.
+// The payload is the unix time in milliseconds when the job is due.
+const JobsChannel = "qs_jobs"
+
+const notifyJobsQuery = `SELECT pg_notify($1, $2)`
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+func notifyJobs(ctx context.Context, e execer, dateTime time.Time) error {
+	_, err := e.Exec(ctx, notifyJobsQuery, JobsChannel, strconv.FormatInt(dateTime.UnixMilli(), 10))
+	return errors.Wrap(err, "notify jobs")
+}
+
+// notifyFutureJob notifies about a job handed back for a later date time. A job which is already due
+// is reported by the release of its queue slot.
+func notifyFutureJob(ctx context.Context, e execer, dateTime time.Time) error {
+	if !dateTime.After(time.Now()) {
+		return nil
+	}
+	return notifyJobs(ctx, e, dateTime)
+}
+
+func (s *Storage) ListenJobs(ctx context.Context, notify func(dateTime time.Time)) error {
+	conn, err := pgx.ConnectConfig(ctx, s.pool.Config().ConnConfig)
+	if err != nil {
+		return errors.Wrap(err, "connect")
+	}
+	defer func() { _ = conn.Close(context.Background()) }()
+
+	if _, err = conn.Exec(ctx, "LISTEN "+JobsChannel); err != nil {
+		return errors.Wrap(err, "listen")
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return errors.Wrap(err, "wait for notification")
+		}
+		millis, err := strconv.ParseInt(notification.Payload, 10, 64)
+		if err != nil {
+			notify(time.Now())
+			continue
+		}
+		notify(time.UnixMilli(millis))
+	}
+}
+
+// getNextJobDateTimeQuery also takes into account due jobs of rate limited queues,
+// which become available once the bucket of the queue has a token again.
+const getNextJobDateTimeQuery = `
+	SELECT min(next.date_time)
+	FROM (
+		SELECT min(date_time) AS date_time FROM jobs WHERE state = 'new'::JOB_STATE AND date_time > now()
+		UNION ALL
+		SELECT min(q.rate_updated_at + (1 - q.rate_tokens) * q.rate_interval / q.rate_limit) AS date_time
+		FROM queues AS q
+		WHERE q.rate_limit IS NOT NULL AND q.rate_tokens < 1
+			AND q.rate_updated_at + (1 - q.rate_tokens) * q.rate_interval / q.rate_limit > now()
+			AND EXISTS (
+				SELECT 1 FROM jobs AS j
+				WHERE j.ref_queue_id = q.id AND j.state = 'new'::JOB_STATE AND j.date_time <= now()
+			)
+	) AS next`
+
+func (s *Storage) GetNextJobDateTime(ctx context.Context) (*time.Time, error) {
+	var dateTime *time.Time
+	if err := pgxscan.Get(ctx, s.pool, &dateTime, getNextJobDateTimeQuery); err != nil {
+		return nil, errors.Wrap(err, "pgxscan get")
+	}
+	return dateTime, nil
+}
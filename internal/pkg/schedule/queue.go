@@ -1,14 +1,41 @@
 package schedule
 
+import "time"
+
 type QueueState = string
 
+// Jobs of a paused queue are not taken into work. A draining queue rejects new jobs,
+// while the jobs it already has are taken into work as usual.
 const (
-	QueueStateReady = JobState("ready")
-	QueueStateBusy  = JobState("busy")
+	QueueStateReady    = QueueState("ready")
+	QueueStatePaused   = QueueState("paused")
+	QueueStateDraining = QueueState("draining")
 )
 
+const DefaultMaxConcurrency = 1
+
+// MinLeaseDuration is twice the default heart beat duration of workers, a shorter lease would let
+// the checker recover healthy jobs whose heart beat is just a bit late.
+const MinLeaseDuration = time.Minute
+
 type Queue struct {
-	ID      int64      `db:"id"`
-	QueueID string     `db:"queue_id"`
-	State   QueueState `db:"state"`
+	ID             int64      `db:"id"`
+	QueueID        string     `db:"queue_id"`
+	State          QueueState `db:"state"`
+	MaxConcurrency int        `db:"max_concurrency"`
+	Running        int        `db:"running"`
+
+	RateLimit    *int           `db:"rate_limit"`
+	RateInterval time.Duration  `db:"rate_interval"`
+	JobTimeout   *time.Duration `db:"job_timeout"`
+	// LeaseDuration is how long a running job of the queue may go without a heart beat before
+	// the checker treats it as stuck, nil means the checker default. It's at least MinLeaseDuration.
+	LeaseDuration *time.Duration `db:"lease_duration"`
+	Retention     *time.Duration `db:"retention"`
+}
+
+type QueueStats struct {
+	Queue
+	Backlog    int `db:"backlog"`
+	DueBacklog int `db:"due_backlog"`
 }
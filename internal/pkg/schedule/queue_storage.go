@@ -0,0 +1,194 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// rateTokensExpression is the amount of jobs the queue q may start now according to its token bucket,
+// it's NULL for queues without a rate limit. The bucket refills with rate_limit tokens every rate_interval
+// and holds at most rate_limit tokens. A bucket which hasn't been used yet is full.
+const rateTokensExpression = `
+	CASE WHEN q.rate_limit IS NOT NULL THEN
+		COALESCE(least(q.rate_limit, q.rate_tokens +
+			EXTRACT(EPOCH FROM now() - q.rate_updated_at) * q.rate_limit / EXTRACT(EPOCH FROM q.rate_interval)),
+			q.rate_limit)
+	END`
+
+const queueColumns = `id, queue_id, state, max_concurrency, running, rate_limit, rate_interval, job_timeout,
+	lease_duration, retention`
+
+// QueueSettings holds the queue settings to change, nil fields are left as they are.
+// Zero RateLimit removes the rate limit of the queue, zero JobTimeout removes the job timeout,
+// zero LeaseDuration brings back the default lease of the checker, and zero Retention brings back
+// the default retention. A non-zero LeaseDuration must be at least MinLeaseDuration.
+type QueueSettings struct {
+	MaxConcurrency *int
+	RateLimit      *int
+	RateInterval   *time.Duration
+	JobTimeout     *time.Duration
+	LeaseDuration  *time.Duration
+	Retention      *time.Duration
+}
+
+const updateQueueSettingsQuery = `
+	INSERT INTO queues (queue_id, max_concurrency, rate_limit, rate_interval, job_timeout, lease_duration,
+		retention)
+	VALUES ($1, COALESCE($2::INT, 1), NULLIF($3::INT, 0), COALESCE($4::INTERVAL, '1 second'::INTERVAL),
+		NULLIF($5::INTERVAL, '0'::INTERVAL), NULLIF($6::INTERVAL, '0'::INTERVAL),
+		NULLIF($7::INTERVAL, '0'::INTERVAL))
+	ON CONFLICT (queue_id) DO UPDATE
+	SET max_concurrency = COALESCE($2::INT, queues.max_concurrency),
+		rate_limit = CASE WHEN $3::INT IS NULL THEN queues.rate_limit ELSE NULLIF($3::INT, 0) END,
+		rate_interval = COALESCE($4::INTERVAL, queues.rate_interval),
+		rate_tokens = CASE WHEN $3::INT IS NULL AND $4::INTERVAL IS NULL THEN queues.rate_tokens END,
+		rate_updated_at = CASE WHEN $3::INT IS NULL AND $4::INTERVAL IS NULL THEN queues.rate_updated_at END,
+		job_timeout = CASE WHEN $5::INTERVAL IS NULL THEN queues.job_timeout ELSE NULLIF($5::INTERVAL, '0'::INTERVAL) END,
+		lease_duration = CASE WHEN $6::INTERVAL IS NULL THEN queues.lease_duration
+			ELSE NULLIF($6::INTERVAL, '0'::INTERVAL) END,
+		retention = CASE WHEN $7::INTERVAL IS NULL THEN queues.retention ELSE NULLIF($7::INTERVAL, '0'::INTERVAL) END
+	RETURNING ` + queueColumns
+
+// Lowering max_concurrency below the amount of running jobs doesn't stop them,
+// new jobs are just not taken until the queue has a free slot. Changing the rate limit refills the bucket.
+func (s *Storage) UpdateQueueSettings(ctx context.Context, queueID string, settings QueueSettings) (Queue, error) {
+	var queue Queue
+	if err := pgxscan.Get(ctx, s.pool, &queue, updateQueueSettingsQuery,
+		queueID, settings.MaxConcurrency, settings.RateLimit, settings.RateInterval, settings.JobTimeout,
+		settings.LeaseDuration, settings.Retention); err != nil {
+		return Queue{}, errors.Wrap(err, "pgxscan get")
+	}
+	// more free slots may let due jobs run right away
+	if err := notifyJobs(ctx, s.pool, time.Now()); err != nil {
+		return Queue{}, err
+	}
+	return queue, nil
+}
+
+const setQueueStateQuery = `
+	UPDATE queues SET state = $1 WHERE queue_id = $2
+	RETURNING ` + queueColumns
+
+func (s *Storage) SetQueueState(ctx context.Context, queueID string, state QueueState) (Queue, error) {
+	var queue Queue
+	err := pgxscan.Get(ctx, s.pool, &queue, setQueueStateQuery, state, queueID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Queue{}, ErrQueueNotFound
+	}
+	if err != nil {
+		return Queue{}, errors.Wrap(err, "pgxscan get")
+	}
+	if state != QueueStatePaused {
+		if err = notifyJobs(ctx, s.pool, time.Now()); err != nil {
+			return Queue{}, err
+		}
+	}
+	return queue, nil
+}
+
+const getQueuesStatsQuery = `
+	SELECT q.id, q.queue_id, q.state, q.max_concurrency, q.running, q.rate_limit, q.rate_interval, q.job_timeout,
+		q.lease_duration, q.retention,
+		count(j.id) AS backlog, count(j.id) FILTER (WHERE j.date_time <= now()) AS due_backlog
+	FROM queues AS q
+	LEFT JOIN jobs AS j
+		ON j.ref_queue_id = q.id AND j.state = 'new'::JOB_STATE
+	GROUP BY q.id
+	ORDER BY q.queue_id`
+
+func (s *Storage) GetQueuesStats(ctx context.Context) ([]QueueStats, error) {
+	var stats []QueueStats
+	if err := pgxscan.Select(ctx, s.pool, &stats, getQueuesStatsQuery); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return stats, nil
+}
+
+const getDueBacklogQuery = `
+	SELECT count(*)
+	FROM jobs AS j
+	INNER JOIN queues AS q
+		ON j.ref_queue_id = q.id
+	WHERE j.state = 'new'::JOB_STATE AND j.date_time <= now() AND q.state <> 'paused'::QUEUE_STATE`
+
+func (s *Storage) GetDueBacklog(ctx context.Context) (int, error) {
+	var backlog int
+	if err := pgxscan.Get(ctx, s.pool, &backlog, getDueBacklogQuery); err != nil {
+		return 0, errors.Wrap(err, "pgxscan get")
+	}
+	return backlog, nil
+}
+
+type QueueRunningRepair struct {
+	ID            int64  `db:"id"`
+	QueueID       string `db:"queue_id"`
+	Running       int    `db:"running"`
+	ActualRunning int    `db:"actual_running"`
+}
+
+const getQueuesWithRunningMismatchQuery = `
+	SELECT q.id, q.queue_id, q.running, count(j.id) AS actual_running
+	FROM queues AS q
+	LEFT JOIN jobs AS j
+		ON j.ref_queue_id = q.id AND j.state = 'running'::JOB_STATE
+	GROUP BY q.id
+	HAVING q.running <> count(j.id)
+	ORDER BY q.queue_id`
+
+func (s *Storage) GetQueuesWithRunningMismatch(ctx context.Context) ([]QueueRunningRepair, error) {
+	var queues []QueueRunningRepair
+	if err := pgxscan.Select(ctx, s.pool, &queues, getQueuesWithRunningMismatchQuery); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return queues, nil
+}
+
+const lockQueueRunningQuery = `SELECT id, queue_id, running FROM queues WHERE id = $1 FOR UPDATE`
+
+const countRunningJobsQuery = `SELECT count(*) FROM jobs WHERE ref_queue_id = $1 AND state = 'running'::JOB_STATE`
+
+const setQueueRunningQuery = `UPDATE queues SET running = $1 WHERE id = $2`
+
+// The queue row is locked before counting, so claims and transitions of the queue in progress
+// are either counted or wait for the repair. Nil is returned when the counter is already right.
+func (s *Storage) RepairQueueRunning(ctx context.Context, queueInternalID int64) (*QueueRunningRepair, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin tx")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var repair QueueRunningRepair
+	err = pgxscan.Get(ctx, tx, &repair, lockQueueRunningQuery, queueInternalID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrQueueNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "lock queue")
+	}
+	if err = pgxscan.Get(ctx, tx, &repair.ActualRunning, countRunningJobsQuery, queueInternalID); err != nil {
+		return nil, errors.Wrap(err, "count running jobs")
+	}
+	if repair.Running == repair.ActualRunning {
+		return nil, nil
+	}
+
+	if _, err = tx.Exec(ctx, setQueueRunningQuery, repair.ActualRunning, queueInternalID); err != nil {
+		return nil, errors.Wrap(err, "set queue running")
+	}
+	// freed slots may let due jobs of the queue run right away
+	if repair.ActualRunning < repair.Running {
+		if err = notifyJobs(ctx, tx, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, errors.Wrap(err, "commit tx")
+	}
+	return &repair, nil
+}
@@ -0,0 +1,333 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func createTestRateLimitedQueue(t *testing.T, s *Storage,
+	queueID string, rateLimit int, rateInterval time.Duration) int64 {
+	t.Helper()
+	maxConcurrency := 1000
+	queue, err := s.UpdateQueueSettings(context.Background(), queueID, QueueSettings{
+		MaxConcurrency: &maxConcurrency,
+		RateLimit:      &rateLimit,
+		RateInterval:   &rateInterval,
+	})
+	if err != nil {
+		t.Fatalf("create queue: %v", err)
+	}
+	return queue.ID
+}
+
+func setTestRateBucket(t *testing.T, s *Storage, queueInternalID int64, tokens float64, ago time.Duration) {
+	t.Helper()
+	if _, err := s.pool.Exec(context.Background(),
+		"UPDATE queues SET rate_tokens = $1, rate_updated_at = now() - $2::INTERVAL WHERE id = $3",
+		tokens, ago, queueInternalID); err != nil {
+		t.Fatalf("set rate bucket: %v", err)
+	}
+}
+
+func takeTestJobs(t *testing.T, s *Storage, n int) []Job {
+	t.Helper()
+	jobs, err := s.TakeJobsIntoWork(context.Background(), "worker", n, 0)
+	if err != nil {
+		t.Fatalf("take jobs into work: %v", err)
+	}
+	return jobs
+}
+
+func TestTakeJobsIntoWorkRateLimitBurst(t *testing.T) {
+	s := newTestStorage(t)
+	queueInternalID := createTestRateLimitedQueue(t, s, "a", 5, time.Hour)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 20)
+
+	// an unused bucket is full, so the whole limit may start at once and nothing after it
+	if jobs := takeTestJobs(t, s, 100); len(jobs) != 5 {
+		t.Errorf("first claim took %d jobs, want 5", len(jobs))
+	}
+	if jobs := takeTestJobs(t, s, 100); len(jobs) != 0 {
+		t.Errorf("claim from an empty bucket took %d jobs, want 0", len(jobs))
+	}
+}
+
+func TestTakeJobsIntoWorkRateLimitRefill(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tokens float64
+		ago    time.Duration
+		want   int
+	}{
+		{name: "empty", tokens: 0, ago: 0, want: 0},
+		{name: "fraction is floored", tokens: 0.9, ago: 0, want: 0},
+		{name: "refilled by elapsed time", tokens: 0, ago: 25 * time.Minute, want: 2},
+		{name: "refill with leftover fraction", tokens: 0.5, ago: 12 * time.Minute, want: 1},
+		{name: "refill is capped by the limit", tokens: 0, ago: 10 * time.Hour, want: 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			queueInternalID := createTestRateLimitedQueue(t, s, "a", 5, time.Hour)
+			insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 20)
+			setTestRateBucket(t, s, queueInternalID, tc.tokens, tc.ago)
+
+			if jobs := takeTestJobs(t, s, 100); len(jobs) != tc.want {
+				t.Errorf("took %d jobs, want %d", len(jobs), tc.want)
+			}
+		})
+	}
+}
+
+func TestTakeJobsIntoWorkKeepsFractionalTokens(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestRateLimitedQueue(t, s, "a", 5, time.Hour)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 20)
+	// 25 minutes refill 2.08 tokens
+	setTestRateBucket(t, s, queueInternalID, 0, 25*time.Minute)
+
+	if jobs := takeTestJobs(t, s, 100); len(jobs) != 2 {
+		t.Fatalf("took %d jobs, want 2", len(jobs))
+	}
+	var tokens float64
+	if err := s.pool.QueryRow(ctx, "SELECT rate_tokens FROM queues WHERE id = $1",
+		queueInternalID).Scan(&tokens); err != nil {
+		t.Fatalf("select rate tokens: %v", err)
+	}
+	if tokens < 0.08 || tokens > 0.09 {
+		t.Errorf("rate tokens = %f, want the leftover fraction of about 0.083", tokens)
+	}
+}
+
+func TestUpdateQueueSettingsResetsRateBucket(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestRateLimitedQueue(t, s, "a", 5, time.Hour)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 20)
+	if jobs := takeTestJobs(t, s, 100); len(jobs) != 5 {
+		t.Fatalf("first claim took %d jobs, want 5", len(jobs))
+	}
+
+	// settings other than the rate limit keep the drained bucket
+	maxConcurrency := 500
+	if _, err := s.UpdateQueueSettings(ctx, "a", QueueSettings{MaxConcurrency: &maxConcurrency}); err != nil {
+		t.Fatalf("update queue settings: %v", err)
+	}
+	if jobs := takeTestJobs(t, s, 100); len(jobs) != 0 {
+		t.Errorf("claim after a concurrency change took %d jobs, want 0", len(jobs))
+	}
+
+	rateLimit := 3
+	if _, err := s.UpdateQueueSettings(ctx, "a", QueueSettings{RateLimit: &rateLimit}); err != nil {
+		t.Fatalf("update queue settings: %v", err)
+	}
+	if jobs := takeTestJobs(t, s, 100); len(jobs) != 3 {
+		t.Errorf("claim after a rate limit change took %d jobs, want the new limit of 3", len(jobs))
+	}
+}
+
+func TestTakeJobsIntoWorkRateLimitUnderContention(t *testing.T) {
+	const (
+		workers   = 8
+		rateLimit = 10
+	)
+	s := newTestStorage(t)
+	queueInternalID := createTestRateLimitedQueue(t, s, "a", rateLimit, time.Hour)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 50)
+
+	var (
+		mu      sync.Mutex
+		taken   = make(map[int64]int)
+		start   = make(chan struct{})
+		errs    = make(chan error, workers)
+		waitAll sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		waitAll.Add(1)
+		go func() {
+			defer waitAll.Done()
+			<-start
+			jobs, err := s.TakeJobsIntoWork(context.Background(), "worker", 5, 0)
+			if err != nil {
+				errs <- err
+				return
+			}
+			mu.Lock()
+			for i := range jobs {
+				taken[jobs[i].ID]++
+			}
+			mu.Unlock()
+		}()
+	}
+	close(start)
+	waitAll.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("take jobs into work: %v", err)
+	}
+
+	// claims skipped by locked queues are made up by a later claim, but never beyond the bucket
+	for _, job := range takeTestJobs(t, s, 100) {
+		taken[job.ID]++
+	}
+	if len(taken) != rateLimit {
+		t.Errorf("took %d jobs, want the rate limit of %d", len(taken), rateLimit)
+	}
+	for id, times := range taken {
+		if times != 1 {
+			t.Errorf("job %d was taken %d times", id, times)
+		}
+	}
+}
+
+func TestGetNextJobDateTimeWaitsForRateTokens(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestRateLimitedQueue(t, s, "a", 1, time.Hour)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 2)
+
+	next, err := s.GetNextJobDateTime(ctx)
+	if err != nil {
+		t.Fatalf("get next job date time: %v", err)
+	}
+	if next != nil {
+		t.Errorf("next job date time = %v with a full bucket and only due jobs, want nil", next)
+	}
+
+	claimedAt := time.Now()
+	if jobs := takeTestJobs(t, s, 100); len(jobs) != 1 {
+		t.Fatalf("took %d jobs, want 1", len(jobs))
+	}
+	if next, err = s.GetNextJobDateTime(ctx); err != nil {
+		t.Fatalf("get next job date time: %v", err)
+	}
+	// the due job left waits for the token refilled an interval after the claim
+	refilledAt := claimedAt.Add(time.Hour)
+	if next == nil || next.Before(refilledAt.Add(-time.Minute)) || next.After(refilledAt.Add(time.Minute)) {
+		t.Errorf("next job date time = %v, want about %v", next, refilledAt)
+	}
+
+	futureQueueInternalID := createTestQueue(t, s, "b", 1)
+	futureDateTime := time.Now().Add(10 * time.Minute).Truncate(time.Millisecond)
+	insertTestJobs(t, s, futureQueueInternalID, futureDateTime, 1)
+	if next, err = s.GetNextJobDateTime(ctx); err != nil {
+		t.Fatalf("get next job date time: %v", err)
+	}
+	if next == nil || !next.Equal(futureDateTime) {
+		t.Errorf("next job date time = %v, want the earlier future job at %v", next, futureDateTime)
+	}
+}
+
+func TestTakeJobsIntoWorkSkipsPausedQueues(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestQueue(t, s, "a", 10)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 3)
+
+	if _, err := s.SetQueueState(ctx, "a", QueueStatePaused); err != nil {
+		t.Fatalf("pause queue: %v", err)
+	}
+	if jobs := takeTestJobs(t, s, 10); len(jobs) != 0 {
+		t.Errorf("paused queue gave %d jobs", len(jobs))
+	}
+	if _, err := s.SetQueueState(ctx, "a", QueueStateReady); err != nil {
+		t.Fatalf("resume queue: %v", err)
+	}
+	if jobs := takeTestJobs(t, s, 10); len(jobs) != 3 {
+		t.Errorf("resumed queue gave %d jobs, want 3", len(jobs))
+	}
+}
+
+func TestInsertJobRejectsDrainingQueue(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestQueue(t, s, "a", 10)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 1)
+	keptID, _, err := s.InsertJobIdempotent(ctx, Job{DateTime: time.Now(), Action: "test",
+		Queue: Queue{QueueID: "a"}}, "kept", time.Hour)
+	if err != nil {
+		t.Fatalf("insert idempotent job: %v", err)
+	}
+
+	if _, err = s.SetQueueState(ctx, "a", QueueStateDraining); err != nil {
+		t.Fatalf("drain queue: %v", err)
+	}
+	job := Job{DateTime: time.Now(), Action: "test", Queue: Queue{QueueID: "a"}}
+	if _, err = s.InsertJob(ctx, job); !errors.Is(err, ErrQueueDraining) {
+		t.Errorf("insert into a draining queue: err = %v, want ErrQueueDraining", err)
+	}
+	if _, _, err = s.InsertJobIdempotent(ctx, job, "new", time.Hour); !errors.Is(err, ErrQueueDraining) {
+		t.Errorf("idempotent insert into a draining queue: err = %v, want ErrQueueDraining", err)
+	}
+	id, inserted, err := s.InsertJobIdempotent(ctx, job, "kept", time.Hour)
+	if err != nil || inserted || id != keptID {
+		t.Errorf("repeated key of a draining queue: id = %d, inserted = %v, err = %v, want job %d",
+			id, inserted, err, keptID)
+	}
+	// jobs accepted before draining are still claimed
+	if jobs := takeTestJobs(t, s, 10); len(jobs) != 2 {
+		t.Errorf("draining queue gave %d jobs, want 2", len(jobs))
+	}
+}
+
+func TestReplayDeadJobsRejectsDrainingQueue(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestQueue(t, s, "a", 10)
+	finishedAgo := time.Hour
+	id := insertTestFinishedJob(t, s, queueInternalID, JobStateDead, finishedAgo, &finishedAgo)
+
+	if _, err := s.SetQueueState(ctx, "a", QueueStateDraining); err != nil {
+		t.Fatalf("drain queue: %v", err)
+	}
+	if _, err := s.ReplayDeadJobs(ctx, "a", nil, nil); !errors.Is(err, ErrQueueDraining) {
+		t.Errorf("replay into a draining queue: err = %v, want ErrQueueDraining", err)
+	}
+	if _, err := s.SetQueueState(ctx, "a", QueueStateReady); err != nil {
+		t.Fatalf("resume queue: %v", err)
+	}
+	ids, err := s.ReplayDeadJobs(ctx, "a", nil, nil)
+	if err != nil {
+		t.Fatalf("replay dead jobs: %v", err)
+	}
+	assertJobIDs(t, "replayed", ids, []int64{id})
+}
+
+func TestRepairQueueRunningFixesDriftedCounter(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestQueue(t, s, "a", 10)
+	createTestQueue(t, s, "b", 10)
+	insertTestJobs(t, s, queueInternalID, time.Now().Add(-time.Minute), 3)
+	if jobs := takeTestJobs(t, s, 2); len(jobs) != 2 {
+		t.Fatalf("took %d jobs, want 2", len(jobs))
+	}
+	if _, err := s.pool.Exec(ctx, "UPDATE queues SET running = 7 WHERE id = $1", queueInternalID); err != nil {
+		t.Fatalf("drift running counter: %v", err)
+	}
+
+	queues, err := s.GetQueuesWithRunningMismatch(ctx)
+	if err != nil {
+		t.Fatalf("get queues with running mismatch: %v", err)
+	}
+	if len(queues) != 1 || queues[0].ID != queueInternalID || queues[0].Running != 7 || queues[0].ActualRunning != 2 {
+		t.Fatalf("queues with running mismatch = %+v, want queue a running 7 of actual 2", queues)
+	}
+	repair, err := s.RepairQueueRunning(ctx, queueInternalID)
+	if err != nil {
+		t.Fatalf("repair queue running: %v", err)
+	}
+	if repair == nil || repair.ActualRunning != 2 {
+		t.Errorf("repair = %+v, want the counter set to 2", repair)
+	}
+	if repair, err = s.RepairQueueRunning(ctx, queueInternalID); err != nil || repair != nil {
+		t.Errorf("repair of a right counter = %+v, err = %v, want nil", repair, err)
+	}
+	if queues, err = s.GetQueuesWithRunningMismatch(ctx); err != nil || len(queues) != 0 {
+		t.Errorf("queues with running mismatch after the repair = %+v, err = %v", queues, err)
+	}
+}
@@ -0,0 +1,17 @@
+package schedule
+
+type StuckJobAction = string
+
+const (
+	// StuckJobActionRequeue returns the job to the new state, the stuck execution isn't counted as an attempt.
+	StuckJobActionRequeue = StuckJobAction("requeue")
+	StuckJobActionFail    = StuckJobAction("fail")
+	// StuckJobActionAlert only records the stuck job and leaves it running.
+	StuckJobActionAlert = StuckJobAction("alert")
+)
+
+type StuckJob struct {
+	Job
+	// Recoveries is how many times the job has already been requeued as stuck.
+	Recoveries int `db:"recoveries"`
+}
@@ -0,0 +1,88 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/pkg/errors"
+)
+
+// getStuckJobsQuery takes the lease of the queue of a job, or the default lease $1 when the queue has none.
+const getStuckJobsQuery = `
+	SELECT j.id, j.date_time, j.action, j.payload, j.state, j.last_heart_beat,
+		j.attempts, j.max_attempts, j.last_error, j.last_error_kind, j.failed_at,
+		j.worker_id, j.lease_token, j.priority, j.ref_queue_id AS "queue.id", q.queue_id AS "queue.queue_id",
+		(
+			SELECT count(*) FROM job_recoveries AS r
+			WHERE r.ref_job_id = j.id AND r.action = 'requeue'::STUCK_JOB_ACTION
+		) AS recoveries
+	FROM jobs AS j
+	INNER JOIN queues AS q
+		ON j.ref_queue_id = q.id
+	WHERE j.state = 'running'::JOB_STATE AND j.last_heart_beat IS NOT NULL
+		AND j.last_heart_beat < now() - COALESCE(q.lease_duration, $1::INTERVAL)`
+
+func (s *Storage) GetStuckJobs(ctx context.Context, defaultLeaseDuration time.Duration) ([]StuckJob, error) {
+	var jobs []StuckJob
+	if err := pgxscan.Select(ctx, s.pool, &jobs, getStuckJobsQuery, defaultLeaseDuration); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return jobs, nil
+}
+
+const requeueStuckJobQuery = `
+	UPDATE jobs SET state = 'new'::JOB_STATE, last_error = $1, last_error_kind = $2, last_heart_beat = now()
+	WHERE id = $3 AND lease_token = $4 AND state = 'running'::JOB_STATE`
+
+const insertJobRecoveryQuery = `
+	INSERT INTO job_recoveries (ref_job_id, ref_queue_id, worker_id, lease_token, last_heart_beat, action)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (ref_job_id, lease_token, action) DO NOTHING`
+
+// RecoverStuckJob applies the action to the stuck job and records the recovery in the same transaction.
+// A lease is recorded once per action, so false is returned when the job has already been recovered
+// with the action, e.g. by a previous alert. ErrLeaseLost is returned and nothing is recorded
+// when the job has left the lease meanwhile.
+func (s *Storage) RecoverStuckJob(ctx context.Context, job Job, action StuckJobAction, reason string) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "begin tx")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, insertJobRecoveryQuery,
+		job.ID, job.Queue.ID, job.WorkerID, job.LeaseToken, job.LastHeartBeat, action)
+	if err != nil {
+		return false, errors.Wrap(err, "insert job recovery")
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	var hasDueJobs bool
+	switch action {
+	case StuckJobActionRequeue:
+		hasDueJobs, err = s.updateWithTx(ctx, tx, job, JobEventTypeRecovered, &reason,
+			requeueStuckJobQuery, []interface{}{reason, JobErrorKindLeaseExpired, job.ID, job.LeaseToken})
+	case StuckJobActionFail:
+		hasDueJobs, err = s.updateWithTx(ctx, tx, job, JobEventTypeFailed, &reason,
+			failJobQuery, []interface{}{reason, JobErrorKindLeaseExpired, job.ID, job.LeaseToken})
+	case StuckJobActionAlert:
+	default:
+		err = errors.Errorf("unsupported stuck job action = %q", action)
+	}
+	if err != nil {
+		return false, err
+	}
+	if hasDueJobs {
+		if err = notifyJobs(ctx, tx, time.Now()); err != nil {
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, errors.Wrap(err, "commit tx")
+	}
+	return true, nil
+}
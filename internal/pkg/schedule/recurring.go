@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+type CatchUpPolicy = string
+
+const (
+	CatchUpPolicySkip = CatchUpPolicy("skip")
+	CatchUpPolicyOnce = CatchUpPolicy("once")
+	CatchUpPolicyAll  = CatchUpPolicy("all")
+)
+
+type RecurringJob struct {
+	ID             int64           `db:"id"`
+	Name           string          `db:"name"`
+	CronExpression string          `db:"cron_expression"`
+	Timezone       string          `db:"timezone"`
+	Action         string          `db:"action"`
+	Payload        json.RawMessage `db:"payload"`
+	MaxAttempts    int             `db:"max_attempts"`
+	CatchUpPolicy  CatchUpPolicy   `db:"catch_up_policy"`
+	NextRunAt      *time.Time      `db:"next_run_at"`
+	RefQueueID     int64           `db:"ref_queue_id"`
+	QueueID        string          `db:"queue_id"`
+}
+
+// cronParser accepts the standard five fields with an optional leading seconds field
+// and descriptors such as @hourly or @every 10m.
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+type CronSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+func ParseCronSchedule(expression, timezone string) (CronSchedule, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return CronSchedule{}, errors.Wrapf(err, "load location = %s", timezone)
+	}
+	schedule, err := cronParser.Parse(expression)
+	if err != nil {
+		return CronSchedule{}, errors.Wrapf(err, "parse cron expression = %s", expression)
+	}
+	return CronSchedule{schedule: schedule, location: location}, nil
+}
+
+func (c CronSchedule) Next(t time.Time) time.Time {
+	return c.schedule.Next(t.In(c.location))
+}
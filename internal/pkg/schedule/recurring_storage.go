@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/pkg/errors"
+)
+
+var ErrRecurringJobNotFound = errors.New("recurring job not found")
+
+const upsertRecurringJobQuery = `
+	INSERT INTO schedules (name, ref_queue_id, cron_expression, timezone, action, payload, max_attempts, catch_up_policy)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (name) DO UPDATE
+	SET ref_queue_id = excluded.ref_queue_id, cron_expression = excluded.cron_expression,
+		timezone = excluded.timezone, action = excluded.action, payload = excluded.payload,
+		max_attempts = excluded.max_attempts, catch_up_policy = excluded.catch_up_policy, next_run_at = NULL
+	RETURNING id`
+
+// Replacing resets the next run time, so the new cron expression takes effect from now on.
+func (s *Storage) UpsertRecurringJob(ctx context.Context, job RecurringJob) (int64, error) {
+	internalQueueID, err := s.getQueueInternalIDOrCreate(ctx, job.QueueID)
+	if err != nil {
+		return 0, errors.Wrap(err, "get queue internal id or create")
+	}
+	maxAttempts := job.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	catchUpPolicy := job.CatchUpPolicy
+	if catchUpPolicy == "" {
+		catchUpPolicy = CatchUpPolicyOnce
+	}
+
+	var id int64
+	if err = pgxscan.Get(ctx, s.pool, &id, upsertRecurringJobQuery,
+		job.Name, internalQueueID, job.CronExpression, job.Timezone, job.Action, payloadArg(job.Payload),
+		maxAttempts, catchUpPolicy); err != nil {
+		return 0, errors.Wrap(err, "pgxscan get")
+	}
+	return id, nil
+}
+
+const getRecurringJobsQuery = `
+	SELECT s.id, s.name, s.cron_expression, s.timezone, s.action, s.payload, s.max_attempts,
+		s.catch_up_policy, s.next_run_at, s.ref_queue_id, q.queue_id
+	FROM schedules AS s
+	INNER JOIN queues AS q
+		ON s.ref_queue_id = q.id
+	WHERE $1 = '' OR q.queue_id = $1
+	ORDER BY s.name`
+
+func (s *Storage) GetRecurringJobs(ctx context.Context, queueID string) ([]RecurringJob, error) {
+	var jobs []RecurringJob
+	if err := pgxscan.Select(ctx, s.pool, &jobs, getRecurringJobsQuery, queueID); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return jobs, nil
+}
+
+const deleteRecurringJobQuery = `DELETE FROM schedules WHERE name = $1`
+
+func (s *Storage) DeleteRecurringJob(ctx context.Context, name string) error {
+	tag, err := s.pool.Exec(ctx, deleteRecurringJobQuery, name)
+	if err != nil {
+		return errors.Wrap(err, "exec query")
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecurringJobNotFound
+	}
+	return nil
+}
+
+const getDueRecurringJobsQuery = `
+	SELECT s.id, s.name, s.cron_expression, s.timezone, s.action, s.payload, s.max_attempts,
+		s.catch_up_policy, s.next_run_at, s.ref_queue_id, q.queue_id
+	FROM schedules AS s
+	INNER JOIN queues AS q
+		ON s.ref_queue_id = q.id
+	WHERE s.next_run_at IS NULL OR s.next_run_at <= $1`
+
+func (s *Storage) GetDueRecurringJobs(ctx context.Context, dateTime time.Time) ([]RecurringJob, error) {
+	var jobs []RecurringJob
+	if err := pgxscan.Select(ctx, s.pool, &jobs, getDueRecurringJobsQuery, dateTime); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return jobs, nil
+}
+
+// insertRecurringJobRunQuery skips runs of draining queues, it affects one row per inserted job.
+const insertRecurringJobRunQuery = `
+	WITH inserted_jobs AS (
+		INSERT INTO jobs (ref_queue_id, date_time, action, payload, max_attempts, ref_schedule_id)
+		SELECT $1, $2::TIMESTAMPTZ, $3::VARCHAR, $4::JSONB, $5::INT, $6::BIGINT
+		FROM queues
+		WHERE id = $1 AND state <> 'draining'::QUEUE_STATE
+		ON CONFLICT (ref_schedule_id, date_time) WHERE ref_schedule_id IS NOT NULL DO NOTHING
+		RETURNING id, state, worker_id, lease_token
+	)
+	INSERT INTO job_events (` + jobEventColumns + `, type)
+	SELECT id, state, worker_id, lease_token, 'created'::JOB_EVENT_TYPE FROM inserted_jobs`
+
+const moveRecurringJobNextRunQuery = `
+	UPDATE schedules SET next_run_at = $1 WHERE id = $2 AND next_run_at IS NOT DISTINCT FROM $3`
+
+// Runs which already have a job are skipped, and nothing is changed if another process
+// has moved the next run time in the meantime, so concurrent calls are safe.
+func (s *Storage) MaterializeRecurringJob(ctx context.Context,
+	job RecurringJob, runs []time.Time, nextRunAt time.Time) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin tx")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, moveRecurringJobNextRunQuery, nextRunAt, job.ID, job.NextRunAt)
+	if err != nil {
+		return 0, errors.Wrap(err, "move next run")
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	var inserted int
+	for i := range runs {
+		tag, err = tx.Exec(ctx, insertRecurringJobRunQuery,
+			job.RefQueueID, runs[i], job.Action, payloadArg(job.Payload), job.MaxAttempts, job.ID)
+		if err != nil {
+			return 0, errors.Wrap(err, "insert job")
+		}
+		inserted += int(tag.RowsAffected())
+	}
+	if inserted > 0 {
+		if err = notifyJobs(ctx, tx, runs[0]); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, errors.Wrap(err, "commit tx")
+	}
+	return inserted, nil
+}
@@ -0,0 +1,8 @@
+package schedule
+
+type RetentionMode = string
+
+const (
+	RetentionModeDelete  = RetentionMode("delete")
+	RetentionModeArchive = RetentionMode("archive")
+)
@@ -0,0 +1,112 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/pkg/errors"
+)
+
+// expiredJobsExpression selects up to $2 finished jobs whose retention is over, taking the retention
+// of the queue of a job or the default retention $1. Queues without a retention keep their jobs
+// when $1 is NULL. Dead jobs wait for an operator to replay them, so they are kept for the dead
+// retention $3 instead, or forever when $3 is NULL. Jobs keep their idempotency key until it expires,
+// so retries of the same request are still deduplicated.
+const expiredJobsExpression = `
+	expired_jobs AS (
+		SELECT j.id
+		FROM jobs AS j
+		INNER JOIN queues AS q
+			ON j.ref_queue_id = q.id
+		WHERE j.finished_at < now() - CASE
+				WHEN j.state = 'dead'::JOB_STATE THEN $3::INTERVAL
+				ELSE COALESCE(q.retention, $1::INTERVAL)
+			END
+			AND (j.idempotency_expires_at IS NULL OR j.idempotency_expires_at < now())
+		ORDER BY j.finished_at
+		LIMIT $2
+		FOR UPDATE OF j SKIP LOCKED
+	)`
+
+const deleteExpiredJobsQuery = `
+	WITH ` + expiredJobsExpression + `, deleted_jobs AS (
+		DELETE FROM jobs AS j
+		USING expired_jobs AS e
+		WHERE j.id = e.id
+		RETURNING j.id
+	)
+	SELECT count(*) FROM deleted_jobs`
+
+// archivedJobColumns are all columns of jobs, jobs_archive mirrors them.
+const archivedJobColumns = `id, ref_queue_id, date_time, action, payload, state, last_heart_beat,
+	attempts, max_attempts, last_error, last_error_kind, failed_at, finished_at, worker_id, lease_token,
+	priority, timeout, ref_schedule_id, idempotency_key, idempotency_expires_at`
+
+// archiveExpiredJobsQuery archives events of the jobs along with them. Events and recoveries are deleted
+// by the cascade of their foreign keys, while the insert of the archived events reads them from the snapshot
+// of the statement.
+const archiveExpiredJobsQuery = `
+	WITH ` + expiredJobsExpression + `, deleted_jobs AS (
+		DELETE FROM jobs AS j
+		USING expired_jobs AS e
+		WHERE j.id = e.id
+		RETURNING j.*
+	), archived_jobs AS (
+		INSERT INTO jobs_archive (` + archivedJobColumns + `)
+		SELECT ` + archivedJobColumns + `
+		FROM deleted_jobs
+		RETURNING id
+	), archived_events AS (
+		INSERT INTO job_events_archive (id, ref_job_id, type, state, worker_id, lease_token, error, created_at)
+		SELECT e.id, e.ref_job_id, e.type, e.state, e.worker_id, e.lease_token, e.error, e.created_at
+		FROM job_events AS e
+		INNER JOIN deleted_jobs AS d
+			ON e.ref_job_id = d.id
+	)
+	SELECT count(*) FROM archived_jobs`
+
+// SweepExpiredJobs deletes or archives, depending on the mode, up to limit finished jobs whose retention
+// is over and returns the amount of swept jobs. Nil defaultRetention keeps jobs of queues without
+// a retention of their own, and nil deadRetention keeps dead jobs. Jobs locked by concurrent transactions
+// are skipped until the next sweep.
+func (s *Storage) SweepExpiredJobs(ctx context.Context,
+	mode RetentionMode, defaultRetention, deadRetention *time.Duration, limit int) (int, error) {
+	var query string
+	switch mode {
+	case RetentionModeDelete:
+		query = deleteExpiredJobsQuery
+	case RetentionModeArchive:
+		query = archiveExpiredJobsQuery
+	default:
+		return 0, errors.Errorf("unsupported retention mode = %q", mode)
+	}
+
+	var swept int
+	if err := pgxscan.Get(ctx, s.pool, &swept, query, defaultRetention, limit, deadRetention); err != nil {
+		return 0, errors.Wrap(err, "pgxscan get")
+	}
+	return swept, nil
+}
+
+// backfillFinishedAtQuery estimates the finish time of jobs which have been finished before finished_at
+// was recorded, by the last time they've been touched.
+const backfillFinishedAtQuery = `
+	UPDATE jobs AS j
+	SET finished_at = COALESCE(j.failed_at, j.last_heart_beat, j.date_time)
+	FROM (
+		SELECT id
+		FROM jobs
+		WHERE finished_at IS NULL AND state IN ('done'::JOB_STATE, 'dead'::JOB_STATE, 'cancelled'::JOB_STATE)
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	) AS b
+	WHERE j.id = b.id`
+
+func (s *Storage) BackfillFinishedAt(ctx context.Context, limit int) (int, error) {
+	tag, err := s.pool.Exec(ctx, backfillFinishedAtQuery, limit)
+	if err != nil {
+		return 0, errors.Wrap(err, "exec query")
+	}
+	return int(tag.RowsAffected()), nil
+}
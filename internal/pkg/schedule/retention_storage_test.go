@@ -0,0 +1,195 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const insertTestFinishedJobQuery = `
+	INSERT INTO jobs (ref_queue_id, date_time, action, state, last_heart_beat, finished_at)
+	VALUES ($1, now() - $3::INTERVAL, 'test', $2, now() - $3::INTERVAL, now() - $4::INTERVAL)
+	RETURNING id`
+
+// insertTestFinishedJob inserts a job last touched lastTouchedAgo and finished finishedAgo,
+// nil finishedAgo leaves finished_at unset as for jobs finished before it was recorded.
+func insertTestFinishedJob(t *testing.T, s *Storage,
+	queueInternalID int64, state JobState, lastTouchedAgo time.Duration, finishedAgo *time.Duration) int64 {
+	t.Helper()
+	var id int64
+	if err := s.pool.QueryRow(context.Background(), insertTestFinishedJobQuery,
+		queueInternalID, state, lastTouchedAgo, finishedAgo).Scan(&id); err != nil {
+		t.Fatalf("insert finished job: %v", err)
+	}
+	return id
+}
+
+func sweepTestJobs(t *testing.T, s *Storage, defaultRetention, deadRetention *time.Duration) int {
+	t.Helper()
+	swept, err := s.SweepExpiredJobs(context.Background(), RetentionModeDelete, defaultRetention, deadRetention, 100)
+	if err != nil {
+		t.Fatalf("sweep expired jobs: %v", err)
+	}
+	return swept
+}
+
+func TestSweepExpiredJobsKeepsDeadJobsForDeadRetention(t *testing.T) {
+	s := newTestStorage(t)
+	queueInternalID := createTestQueue(t, s, "a", 1)
+	finishedAgo := 2 * time.Hour
+	insertTestFinishedJob(t, s, queueInternalID, JobStateDone, finishedAgo, &finishedAgo)
+	insertTestFinishedJob(t, s, queueInternalID, JobStateDead, finishedAgo, &finishedAgo)
+
+	retention := time.Hour
+	if swept := sweepTestJobs(t, s, &retention, nil); swept != 1 {
+		t.Errorf("swept %d jobs without a dead retention, want only the done one", swept)
+	}
+	deadRetention := 3 * time.Hour
+	if swept := sweepTestJobs(t, s, &retention, &deadRetention); swept != 0 {
+		t.Errorf("swept %d dead jobs before the dead retention is over", swept)
+	}
+	deadRetention = time.Hour
+	if swept := sweepTestJobs(t, s, &retention, &deadRetention); swept != 1 {
+		t.Errorf("swept %d jobs after the dead retention is over, want the dead one", swept)
+	}
+}
+
+func TestBackfillFinishedAtLetsRetentionSweepOldJobs(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestQueue(t, s, "a", 1)
+	insertTestFinishedJob(t, s, queueInternalID, JobStateDone, 2*time.Hour, nil)
+	insertTestFinishedJob(t, s, queueInternalID, JobStateCancelled, 2*time.Hour, nil)
+	insertTestJobs(t, s, queueInternalID, time.Now(), 1)
+
+	retention := time.Hour
+	if swept := sweepTestJobs(t, s, &retention, nil); swept != 0 {
+		t.Fatalf("swept %d jobs without finished_at", swept)
+	}
+	backfilled, err := s.BackfillFinishedAt(ctx, 100)
+	if err != nil {
+		t.Fatalf("backfill finished at: %v", err)
+	}
+	if backfilled != 2 {
+		t.Errorf("backfilled %d jobs, want the done and the cancelled ones", backfilled)
+	}
+	if swept := sweepTestJobs(t, s, &retention, nil); swept != 2 {
+		t.Errorf("swept %d backfilled jobs, want 2", swept)
+	}
+}
+
+func TestSweepExpiredJobsDeletesRecoveries(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	queueInternalID := createTestQueue(t, s, "a", 1)
+	finishedAgo := 2 * time.Hour
+	id := insertTestFinishedJob(t, s, queueInternalID, JobStateDone, finishedAgo, &finishedAgo)
+	if _, err := s.pool.Exec(ctx, insertJobRecoveryQuery,
+		id, queueInternalID, "worker", 1, time.Now(), StuckJobActionRequeue); err != nil {
+		t.Fatalf("insert job recovery: %v", err)
+	}
+
+	retention := time.Hour
+	if swept := sweepTestJobs(t, s, &retention, nil); swept != 1 {
+		t.Fatalf("swept %d jobs, want 1", swept)
+	}
+	var recoveries int
+	if err := s.pool.QueryRow(ctx, "SELECT count(*) FROM job_recoveries WHERE ref_job_id = $1",
+		id).Scan(&recoveries); err != nil {
+		t.Fatalf("count job recoveries: %v", err)
+	}
+	if recoveries != 0 {
+		t.Errorf("%d recoveries of a swept job are left", recoveries)
+	}
+}
+
+func TestSweepExpiredJobsHandlesEvents(t *testing.T) {
+	for _, tc := range []struct {
+		mode             RetentionMode
+		wantArchivedJobs int
+	}{
+		{mode: RetentionModeDelete, wantArchivedJobs: 0},
+		{mode: RetentionModeArchive, wantArchivedJobs: 1},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			s := newTestStorage(t)
+			ctx := context.Background()
+			queueInternalID := createTestQueue(t, s, "a", 1)
+			finishedAgo := 2 * time.Hour
+			id := insertTestFinishedJob(t, s, queueInternalID, JobStateDone, finishedAgo, &finishedAgo)
+			for _, eventType := range []JobEventType{JobEventTypeCreated, JobEventTypeClaimed, JobEventTypeFinished} {
+				if err := insertJobEvent(ctx, s.pool, id, eventType, nil); err != nil {
+					t.Fatalf("insert job event: %v", err)
+				}
+			}
+
+			retention := time.Hour
+			swept, err := s.SweepExpiredJobs(ctx, tc.mode, &retention, nil, 100)
+			if err != nil {
+				t.Fatalf("sweep expired jobs: %v", err)
+			}
+			if swept != 1 {
+				t.Fatalf("swept %d jobs, want 1", swept)
+			}
+
+			var events, archivedJobs, archivedEvents int
+			if err = s.pool.QueryRow(ctx, `
+				SELECT (SELECT count(*) FROM job_events WHERE ref_job_id = $1),
+					(SELECT count(*) FROM jobs_archive WHERE id = $1),
+					(SELECT count(*) FROM job_events_archive WHERE ref_job_id = $1)`,
+				id).Scan(&events, &archivedJobs, &archivedEvents); err != nil {
+				t.Fatalf("count events: %v", err)
+			}
+			if events != 0 {
+				t.Errorf("%d events of a swept job are left", events)
+			}
+			if archivedJobs != tc.wantArchivedJobs || archivedEvents != 3*tc.wantArchivedJobs {
+				t.Errorf("archived %d jobs and %d events, want %d jobs with all of their events",
+					archivedJobs, archivedEvents, tc.wantArchivedJobs)
+			}
+		})
+	}
+}
+
+func TestSweepExpiredJobsKeepsUnexpiredIdempotencyKeys(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	id, _, err := s.InsertJobIdempotent(ctx, Job{DateTime: time.Now(), Action: "test", Queue: Queue{QueueID: "a"}},
+		"key", time.Hour)
+	if err != nil {
+		t.Fatalf("insert idempotent job: %v", err)
+	}
+	if _, err = s.pool.Exec(ctx, `
+		UPDATE jobs SET state = 'done'::JOB_STATE, finished_at = now() - INTERVAL '2 hours'
+		WHERE id = $1`, id); err != nil {
+		t.Fatalf("finish job: %v", err)
+	}
+
+	retention := time.Hour
+	if swept := sweepTestJobs(t, s, &retention, nil); swept != 0 {
+		t.Errorf("swept %d jobs whose idempotency key hasn't expired", swept)
+	}
+	if _, err = s.pool.Exec(ctx,
+		"UPDATE jobs SET idempotency_expires_at = now() - INTERVAL '1 minute' WHERE id = $1", id); err != nil {
+		t.Fatalf("expire idempotency key: %v", err)
+	}
+	if swept := sweepTestJobs(t, s, &retention, nil); swept != 1 {
+		t.Errorf("swept %d jobs after the idempotency key has expired, want 1", swept)
+	}
+}
+
+func TestJobsArchiveMirrorsJobs(t *testing.T) {
+	s := newTestStorage(t)
+	var missing []string
+	if err := s.pool.QueryRow(context.Background(), `
+		SELECT coalesce(array_agg(column_name::TEXT ORDER BY column_name), '{}') FROM (
+			SELECT column_name FROM information_schema.columns WHERE table_name = 'jobs'
+			EXCEPT
+			SELECT column_name FROM information_schema.columns WHERE table_name = 'jobs_archive'
+		) AS c`).Scan(&missing); err != nil {
+		t.Fatalf("compare columns: %v", err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("jobs_archive lacks columns %v of jobs", missing)
+	}
+}
@@ -8,80 +8,269 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/pkg/errors"
-	"golang.org/x/sync/errgroup"
+	"go.uber.org/zap"
 )
 
-var ErrNoAvailableJobs = errors.New("no available jobs")
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobNotCancellable = errors.New("job is not cancellable")
+	ErrLeaseLost         = errors.New("job lease is lost")
+	ErrQueueNotFound     = errors.New("queue not found")
+	ErrQueueDraining     = errors.New("queue is draining")
+)
 
 var errAlreadyExists = errors.New("already exists")
 
 type Storage struct {
-	pool *pgxpool.Pool
+	logger *zap.Logger
+	pool   *pgxpool.Pool
 }
 
-func NewStorage(pool *pgxpool.Pool) *Storage {
-	return &Storage{pool: pool}
+func NewStorage(logger *zap.Logger, pool *pgxpool.Pool) *Storage {
+	return &Storage{logger: logger, pool: pool}
 }
 
-const getJobForUpdateQuery = `
-	SELECT j.id, j.date_time, j.action, j.state, j.last_heart_beat, q.id, q.queue_id
-	FROM jobs AS j
-	INNER JOIN queues AS q
-		ON j.ref_queue_id = q.id
-	WHERE j.date_time < $1 AND q.state = 'ready'::QUEUE_STATE
-	LIMIT 1
-	FOR UPDATE SKIP LOCKED
-`
+// takeJobsQuery locks ready queues with free concurrency slots and rate tokens, ranked by the effective priority
+// of their heads, and claims the heads of every locked queue up to its free slots. The effective priority grows
+// by one every $3 seconds a job is overdue, it equals the priority when $3 is zero. Jobs of a queue are taken
+// in oldest-due-first order and jobs due at the same time in insertion order, the heads are served
+// by idx_jobs_queue_head.
+const takeJobsQuery = `
+	WITH queues_to_take AS (
+		SELECT q.id, q.queue_id, q.job_timeout, b.rate_tokens,
+			least(q.max_concurrency - q.running, floor(b.rate_tokens)::INT) AS free_slots,
+			h.effective_priority AS head_priority, h.date_time AS head_date_time, h.id AS head_id
+		FROM queues AS q
+		CROSS JOIN LATERAL (
+			SELECT ` + rateTokensExpression + ` AS rate_tokens
+		) AS b
+		CROSS JOIN LATERAL (
+			SELECT id, date_time,
+				priority + COALESCE(floor(EXTRACT(EPOCH FROM now() - date_time) / NULLIF($3::FLOAT8, 0)), 0)
+					AS effective_priority
+			FROM jobs
+			WHERE ref_queue_id = q.id AND state = 'new'::JOB_STATE AND date_time <= now()
+			ORDER BY date_time, id
+			LIMIT 1
+		) AS h
+		WHERE q.state IN ('ready'::QUEUE_STATE, 'draining'::QUEUE_STATE) AND q.running < q.max_concurrency
+			AND (b.rate_tokens IS NULL OR b.rate_tokens >= 1)
+		ORDER BY h.effective_priority DESC, h.date_time, h.id
+		LIMIT $2
+		FOR NO KEY UPDATE OF q SKIP LOCKED
+	), jobs_to_take AS (
+		SELECT j.id, t.id AS queue_internal_id, t.queue_id, t.job_timeout, t.rate_tokens,
+			t.head_priority, t.head_date_time, t.head_id
+		FROM queues_to_take AS t
+		CROSS JOIN LATERAL (
+			SELECT id, date_time
+			FROM jobs
+			WHERE ref_queue_id = t.id AND state = 'new'::JOB_STATE AND date_time <= now()
+			ORDER BY date_time, id
+			LIMIT t.free_slots
+		) AS j
+		ORDER BY t.head_priority DESC, t.head_date_time, t.head_id, j.date_time, j.id
+		LIMIT $2
+	), taken_jobs AS (
+		UPDATE jobs AS j
+		SET state = 'running'::JOB_STATE, worker_id = $1, lease_token = j.lease_token + 1, last_heart_beat = now()
+		FROM jobs_to_take AS t
+		WHERE j.id = t.id AND j.state = 'new'::JOB_STATE
+		RETURNING j.id, j.date_time, j.action, j.payload, j.state, j.last_heart_beat,
+			j.attempts, j.max_attempts, j.last_error, j.last_error_kind, j.failed_at, j.worker_id, j.lease_token,
+			j.priority, j.timeout, j.idempotency_key, t.queue_internal_id, t.queue_id, t.job_timeout, t.rate_tokens,
+			t.head_priority, t.head_date_time, t.head_id
+	), running_queues AS (
+		UPDATE queues AS q
+		SET running = q.running + t.taken, rate_tokens = t.rate_tokens - t.taken, rate_updated_at = now()
+		FROM (
+			SELECT queue_internal_id, rate_tokens, count(*) AS taken
+			FROM taken_jobs
+			GROUP BY queue_internal_id, rate_tokens
+		) AS t
+		WHERE q.id = t.queue_internal_id
+	), claim_events AS (
+		INSERT INTO job_events (` + jobEventColumns + `, type)
+		SELECT id, state, worker_id, lease_token, 'claimed'::JOB_EVENT_TYPE FROM taken_jobs
+	)
+	SELECT t.id, t.date_time, t.action, t.payload, t.state, t.last_heart_beat,
+		t.attempts, t.max_attempts, t.last_error, t.last_error_kind, t.failed_at, t.worker_id, t.lease_token,
+		t.priority, t.timeout, t.idempotency_key, t.queue_internal_id AS "queue.id", t.queue_id AS "queue.queue_id",
+		t.job_timeout AS "queue.job_timeout"
+	FROM taken_jobs AS t
+	ORDER BY t.head_priority DESC, t.head_date_time, t.head_id, t.date_time, t.id`
 
-func (s *Storage) TakeJobIntoWork(ctx context.Context) (Job, error) {
-	tx, err := s.pool.Begin(ctx)
-	if err != nil {
-		return Job{}, errors.Wrap(err, "begin tx")
+// TakeJobsIntoWork claims up to n due jobs for the worker, every claim issues a new lease token.
+// Queues locked by concurrent claims are skipped, so the result may contain fewer jobs than requested.
+// Every priorityAging a job is overdue raises its priority by one, zero priorityAging disables aging.
+func (s *Storage) TakeJobsIntoWork(ctx context.Context,
+	workerID string, n int, priorityAging time.Duration) ([]Job, error) {
+	var jobs []Job
+	if err := pgxscan.Select(ctx, s.pool, &jobs, takeJobsQuery,
+		workerID, n, priorityAging.Seconds()); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
 	}
-	defer func() { _ = tx.Rollback(ctx) }()
+	return jobs, nil
+}
 
-	var job Job
-	if err = pgxscan.Get(ctx, tx, &job, getJobForUpdateQuery); errors.Is(err, pgx.ErrNoRows) {
-		return Job{}, ErrNoAvailableJobs
+const finishJobQuery = `
+	UPDATE jobs SET state = 'done'::JOB_STATE, last_heart_beat = now(), finished_at = now()
+	WHERE id = $1 AND lease_token = $2 AND state = 'running'::JOB_STATE`
+
+func (s *Storage) FinishJob(ctx context.Context, job Job) error {
+	return s.update(ctx, job, JobEventTypeFinished, nil, finishJobQuery, []interface{}{job.ID, job.LeaseToken})
+}
+
+const renewJobQuery = `
+	UPDATE jobs SET state = 'new'::JOB_STATE, last_heart_beat = now()
+	WHERE id = $1 AND lease_token = $2 AND state = 'running'::JOB_STATE`
+
+func (s *Storage) RenewJob(ctx context.Context, job Job) error {
+	return s.update(ctx, job, JobEventTypeReleased, nil, renewJobQuery, []interface{}{job.ID, job.LeaseToken})
+}
+
+const postponeJobQuery = `
+	UPDATE jobs SET state = 'new'::JOB_STATE, date_time = $1, last_heart_beat = now()
+	WHERE id = $2 AND lease_token = $3 AND state = 'running'::JOB_STATE`
+
+// PostponeJob hands the job back with the given date time, unlike RetryJob it doesn't count an attempt.
+func (s *Storage) PostponeJob(ctx context.Context, job Job, dateTime time.Time) error {
+	if err := s.update(ctx, job, JobEventTypeReleased, nil,
+		postponeJobQuery, []interface{}{dateTime, job.ID, job.LeaseToken}); err != nil {
+		return err
 	}
+	return notifyFutureJob(ctx, s.pool, dateTime)
+}
+
+const heartBeatJobQuery = `
+	UPDATE jobs SET last_heart_beat = now()
+	WHERE id = $1 AND lease_token = $2 AND state = 'running'::JOB_STATE`
+
+func (s *Storage) HeartBeatJob(ctx context.Context, job Job) error {
+	tag, err := s.pool.Exec(ctx, heartBeatJobQuery, job.ID, job.LeaseToken)
 	if err != nil {
-		return Job{}, errors.Wrap(err, "get job for update")
+		return errors.Wrap(err, "exec query")
 	}
-
-	if err = s.updateStateWithTx(ctx, tx, job.ID, JobStateRunning, job.Queue.ID, QueueStateBusy); err != nil {
-		return Job{}, errors.Wrap(err, "set job into running state")
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
 	}
+	return nil
+}
 
-	if err = tx.Commit(ctx); err != nil {
-		return Job{}, errors.Wrap(err, "commit tx")
+const retryJobQuery = `
+	UPDATE jobs
+	SET state = 'new'::JOB_STATE, date_time = $1, attempts = attempts + 1, last_error = $2, last_error_kind = $3,
+		last_heart_beat = now()
+	WHERE id = $4 AND lease_token = $5 AND state = 'running'::JOB_STATE`
+
+func (s *Storage) RetryJob(ctx context.Context,
+	job Job, dateTime time.Time, kind JobErrorKind, reason string) error {
+	if err := s.update(ctx, job, JobEventTypeRetried, &reason,
+		retryJobQuery, []interface{}{dateTime, reason, kind, job.ID, job.LeaseToken}); err != nil {
+		return err
 	}
+	return notifyFutureJob(ctx, s.pool, dateTime)
+}
 
-	return job, nil
+const failJobQuery = `
+	UPDATE jobs
+	SET state = 'dead'::JOB_STATE, attempts = attempts + 1, last_error = $1, last_error_kind = $2, failed_at = now(),
+		last_heart_beat = now(), finished_at = now()
+	WHERE id = $3 AND lease_token = $4 AND state = 'running'::JOB_STATE`
+
+func (s *Storage) FailJob(ctx context.Context, job Job, kind JobErrorKind, reason string) error {
+	return s.update(ctx, job, JobEventTypeFailed, &reason,
+		failJobQuery, []interface{}{reason, kind, job.ID, job.LeaseToken})
 }
 
-func (s *Storage) FinishJob(ctx context.Context, job Job) error {
-	return s.updateState(ctx, job.ID, JobStateDone, job.Queue.ID, QueueStateReady)
+const getDeadJobsQuery = selectJobsQuery + `
+	WHERE q.queue_id = $1 AND j.state = 'dead'::JOB_STATE
+	ORDER BY j.failed_at DESC, j.id DESC
+	LIMIT $2`
+
+func (s *Storage) GetDeadJobs(ctx context.Context, queueID string, limit int) ([]Job, error) {
+	var jobs []Job
+	if err := pgxscan.Select(ctx, s.pool, &jobs, getDeadJobsQuery, queueID, limit); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return jobs, nil
 }
 
-func (s *Storage) RenewJob(ctx context.Context, job Job) error {
-	return s.updateState(ctx, job.ID, JobStateNew, job.Queue.ID, QueueStateReady)
+const replayDeadJobsQuery = `
+	WITH replayed_jobs AS (
+		UPDATE jobs AS j
+		SET state = 'new'::JOB_STATE, date_time = COALESCE($3, now()), attempts = 0, failed_at = NULL,
+			finished_at = NULL
+		FROM queues AS q
+		WHERE j.ref_queue_id = q.id AND q.queue_id = $1 AND q.state <> 'draining'::QUEUE_STATE
+			AND j.state = 'dead'::JOB_STATE AND ($2::BIGINT[] IS NULL OR j.id = ANY($2::BIGINT[]))
+		RETURNING j.id, j.state, j.worker_id, j.lease_token
+	), replay_events AS (
+		INSERT INTO job_events (` + jobEventColumns + `, type)
+		SELECT id, state, worker_id, lease_token, 'replayed'::JOB_EVENT_TYPE FROM replayed_jobs
+	)
+	SELECT id FROM replayed_jobs`
+
+const getQueueStateQuery = `SELECT state FROM queues WHERE queue_id = $1`
+
+// ReplayDeadJobs moves dead jobs of the queue back into the new state. All dead jobs of the queue
+// are replayed when jobIDs is empty, and they are scheduled for now when dateTime is nil.
+// ErrQueueDraining is returned for a draining queue.
+func (s *Storage) ReplayDeadJobs(ctx context.Context,
+	queueID string, jobIDs []int64, dateTime *time.Time) ([]int64, error) {
+	if len(jobIDs) == 0 {
+		jobIDs = nil
+	}
+	var ids []int64
+	if err := pgxscan.Select(ctx, s.pool, &ids, replayDeadJobsQuery, queueID, jobIDs, dateTime); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	if len(ids) == 0 {
+		var states []QueueState
+		if err := pgxscan.Select(ctx, s.pool, &states, getQueueStateQuery, queueID); err != nil {
+			return nil, errors.Wrap(err, "get queue state")
+		}
+		if len(states) > 0 && states[0] == QueueStateDraining {
+			return nil, ErrQueueDraining
+		}
+		return nil, nil
+	}
+
+	notifyAt := time.Now()
+	if dateTime != nil {
+		notifyAt = *dateTime
+	}
+	if err := notifyJobs(ctx, s.pool, notifyAt); err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
-const updateJobStateQuery = `UPDATE jobs SET state = $1, last_heart_beat = now() WHERE id = $2`
-const updateQueueStateQuery = `UPDATE queues SET state = $1 WHERE id = $2`
+const releaseQueueSlotQuery = `
+	UPDATE queues AS q SET running = q.running - 1
+	WHERE q.id = $1 AND q.running > 0
+	RETURNING q.state IN ('ready'::QUEUE_STATE, 'draining'::QUEUE_STATE) AND EXISTS (
+		SELECT 1 FROM jobs
+		WHERE ref_queue_id = q.id AND state = 'new'::JOB_STATE AND date_time <= now()
+	)`
 
-func (s *Storage) updateState(ctx context.Context,
-	jobID int64, jobState JobState,
-	queueID int64, queueState QueueState) error {
+func (s *Storage) update(ctx context.Context,
+	job Job, eventType JobEventType, reason *string, jobQuery string, jobArgs []interface{}) error {
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
 		return errors.Wrap(err, "begin tx")
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	if err = s.updateStateWithTx(ctx, tx, jobID, jobState, queueID, queueState); err != nil {
+	hasDueJobs, err := s.updateWithTx(ctx, tx, job, eventType, reason, jobQuery, jobArgs)
+	if err != nil {
 		return err
 	}
+	if hasDueJobs {
+		if err = notifyJobs(ctx, tx, time.Now()); err != nil {
+			return err
+		}
+	}
 
 	if err = tx.Commit(ctx); err != nil {
 		return errors.Wrap(err, "commit tx")
@@ -89,32 +278,199 @@ func (s *Storage) updateState(ctx context.Context,
 	return nil
 }
 
-func (s *Storage) updateStateWithTx(ctx context.Context, tx pgx.Tx,
-	jobID int64, jobState JobState,
-	queueID int64, queueState QueueState) error {
-	eg, egCtx := errgroup.WithContext(ctx)
-	eg.Go(func() error {
-		_, err := tx.Exec(egCtx, updateJobStateQuery, jobState, jobID)
-		return errors.Wrap(err, "update job state")
-	})
-	eg.Go(func() error {
-		_, err := tx.Exec(egCtx, updateQueueStateQuery, queueState, queueID)
-		return errors.Wrap(err, "update queue state")
-	})
-	return errors.Wrap(eg.Wait(), "update state")
+// updateWithTx applies the job query guarded by the lease token, which moves the job out
+// of the running state, records the transition as an event of the given type and releases
+// the concurrency slot of the job in its queue only when the job query has matched the job,
+// otherwise ErrLeaseLost is returned. It reports whether the freed slot may be taken by a due job.
+func (s *Storage) updateWithTx(ctx context.Context, tx pgx.Tx,
+	job Job, eventType JobEventType, reason *string, jobQuery string, jobArgs []interface{}) (bool, error) {
+	tag, err := tx.Exec(ctx, jobQuery, jobArgs...)
+	if err != nil {
+		return false, errors.Wrap(err, "update job state")
+	}
+	if tag.RowsAffected() == 0 {
+		return false, ErrLeaseLost
+	}
+	if err = insertJobEvent(ctx, tx, job.ID, eventType, reason); err != nil {
+		return false, err
+	}
+	var hasDueJobs bool
+	err = tx.QueryRow(ctx, releaseQueueSlotQuery, job.Queue.ID).Scan(&hasDueJobs)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the counter has drifted below the real amount of running jobs, the reconciler repairs it
+		s.logger.Warn("release queue slot: running counter is already zero",
+			zap.Int64("queueID", job.Queue.ID), zap.Int64("jobID", job.ID))
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "release queue slot")
+	}
+	return hasDueJobs, nil
 }
 
-const inertJobQuery = `INSERT INTO jobs (ref_queue_id, date_time, action) VALUES ($1, $2, $3)`
+// inertJobQuery inserts nothing into a draining queue.
+const inertJobQuery = `
+	INSERT INTO jobs (ref_queue_id, date_time, action, payload, max_attempts, priority, timeout)
+	SELECT $1, $2::TIMESTAMPTZ, $3::VARCHAR, $4::JSONB, $5::INT, $6::INT, $7::INTERVAL
+	FROM queues
+	WHERE id = $1 AND state <> 'draining'::QUEUE_STATE
+	RETURNING id`
 
-func (s *Storage) InsertJob(ctx context.Context, job Job) error {
+func (s *Storage) InsertJob(ctx context.Context, job Job) (int64, error) {
 	internalQueueID, err := s.getQueueInternalIDOrCreate(ctx, job.QueueID)
 	if err != nil {
-		return errors.Wrap(err, "get queue internal id or create")
+		return 0, errors.Wrap(err, "get queue internal id or create")
 	}
-	if _, err = s.pool.Exec(ctx, inertJobQuery, internalQueueID, job.DateTime, job.Action); err != nil {
-		return errors.Wrap(err, "exec query")
+	maxAttempts := job.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
 	}
-	return nil
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin tx")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var id int64
+	err = pgxscan.Get(ctx, tx, &id, inertJobQuery,
+		internalQueueID, job.DateTime, job.Action, payloadArg(job.Payload), maxAttempts, job.Priority, job.Timeout)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrQueueDraining
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "pgxscan get")
+	}
+	if err = insertJobEvent(ctx, tx, id, JobEventTypeCreated, nil); err != nil {
+		return 0, err
+	}
+	if err = notifyJobs(ctx, tx, job.DateTime); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, errors.Wrap(err, "commit tx")
+	}
+	return id, nil
+}
+
+const (
+	expireIdempotencyKeyQuery = `
+		UPDATE jobs SET idempotency_key = NULL, idempotency_expires_at = NULL
+		WHERE ref_queue_id = $1 AND idempotency_key = $2 AND idempotency_expires_at <= now()`
+	insertIdempotentJobQuery = `
+		INSERT INTO jobs (ref_queue_id, date_time, action, payload, max_attempts, priority, timeout,
+			idempotency_key, idempotency_expires_at)
+		SELECT $1, $2::TIMESTAMPTZ, $3::VARCHAR, $4::JSONB, $5::INT, $6::INT, $7::INTERVAL,
+			$8::VARCHAR, now() + $9::INTERVAL
+		FROM queues
+		WHERE id = $1 AND state <> 'draining'::QUEUE_STATE
+		ON CONFLICT (ref_queue_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
+		RETURNING id`
+	getJobIDByIdempotencyKeyQuery = `SELECT id FROM jobs WHERE ref_queue_id = $1 AND idempotency_key = $2`
+)
+
+// InsertJobIdempotent inserts the job unless the queue already has a job with the same
+// idempotency key that has not expired yet. In that case the id of the existing job is
+// returned and inserted is false. The key of a new job expires after the given ttl.
+// ErrQueueDraining is returned when a new job would be inserted into a draining queue.
+func (s *Storage) InsertJobIdempotent(ctx context.Context,
+	job Job, idempotencyKey string, ttl time.Duration) (id int64, inserted bool, err error) {
+	internalQueueID, err := s.getQueueInternalIDOrCreate(ctx, job.QueueID)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "get queue internal id or create")
+	}
+	maxAttempts := job.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "begin tx")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err = tx.Exec(ctx, expireIdempotencyKeyQuery, internalQueueID, idempotencyKey); err != nil {
+		return 0, false, errors.Wrap(err, "expire idempotency key")
+	}
+
+	err = pgxscan.Get(ctx, tx, &id, insertIdempotentJobQuery, internalQueueID, job.DateTime, job.Action,
+		payloadArg(job.Payload), maxAttempts, job.Priority, job.Timeout, idempotencyKey, ttl)
+	switch {
+	case err == nil:
+		inserted = true
+		if err = insertJobEvent(ctx, tx, id, JobEventTypeCreated, nil); err != nil {
+			return 0, false, err
+		}
+		if err = notifyJobs(ctx, tx, job.DateTime); err != nil {
+			return 0, false, err
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		// nothing is inserted either for a known key or into a draining queue
+		err = pgxscan.Get(ctx, tx, &id, getJobIDByIdempotencyKeyQuery, internalQueueID, idempotencyKey)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, ErrQueueDraining
+		}
+		if err != nil {
+			return 0, false, errors.Wrap(err, "get job id by idempotency key")
+		}
+	default:
+		return 0, false, errors.Wrap(err, "insert job")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, false, errors.Wrap(err, "commit tx")
+	}
+	return id, inserted, nil
+}
+
+const getJobQuery = selectJobsQuery + `
+	WHERE j.id = $1`
+
+func (s *Storage) GetJob(ctx context.Context, id int64) (Job, error) {
+	var job Job
+	err := pgxscan.Get(ctx, s.pool, &job, getJobQuery, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Job{}, ErrJobNotFound
+	}
+	if err != nil {
+		return Job{}, errors.Wrap(err, "pgxscan get")
+	}
+	return job, nil
+}
+
+func (s *Storage) GetJobs(ctx context.Context, filter JobsFilter) ([]Job, error) {
+	query, args := filter.query()
+	var jobs []Job
+	if err := pgxscan.Select(ctx, s.pool, &jobs, query, args...); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	return jobs, nil
+}
+
+const cancelJobQuery = `
+	WITH cancelled_jobs AS (
+		UPDATE jobs SET state = 'cancelled'::JOB_STATE, finished_at = now()
+		WHERE id = $1 AND state = 'new'::JOB_STATE
+		RETURNING id, state, worker_id, lease_token
+	)
+	INSERT INTO job_events (` + jobEventColumns + `, type)
+	SELECT id, state, worker_id, lease_token, 'cancelled'::JOB_EVENT_TYPE FROM cancelled_jobs`
+
+func (s *Storage) CancelJob(ctx context.Context, id int64) (Job, error) {
+	tag, err := s.pool.Exec(ctx, cancelJobQuery, id)
+	if err != nil {
+		return Job{}, errors.Wrap(err, "exec query")
+	}
+
+	job, err := s.GetJob(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return job, ErrJobNotCancellable
+	}
+	return job, nil
 }
 
 func (s *Storage) getQueueInternalIDOrCreate(ctx context.Context, queueID string) (int64, error) {
@@ -160,18 +516,3 @@ func (s *Storage) createQueue(ctx context.Context, queueID string) (int64, error
 	}
 	return 0, errAlreadyExists
 }
-
-const getRunningJobsForTooLongQuery = `
-	SELECT j.id, j.date_time, j.action, j.state, j.last_heart_beat, q.id, q.queue_id
-	FROM jobs AS j
-	INNER JOIN queues AS q
-		ON j.ref_queue_id = q.id
-	WHERE j.last_heart_beat IS NOT NULL AND j.last_heart_beat < $1 AND q.state = 'busy'::QUEUE_STATE`
-
-func (s *Storage) GetRunningJobsForTooLong(ctx context.Context, dateTime time.Time) ([]Job, error) {
-	var jobs []Job
-	if err := pgxscan.Select(ctx, s.pool, &jobs, getRunningJobsForTooLongQuery, dateTime); err != nil {
-		return nil, errors.Wrap(err, "pgxscan select")
-	}
-	return jobs, nil
-}
@@ -0,0 +1,43 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/pkg/errors"
+)
+
+// touchWorkerInstanceQuery marks the instance as seen, forgets instances which haven't been seen
+// for longer than $2 and counts the seen ones. The count is taken from the snapshot before the touch,
+// so the instance itself is added to the others.
+const touchWorkerInstanceQuery = `
+	WITH touched_instance AS (
+		INSERT INTO worker_instances (worker_id, seen_at)
+		VALUES ($1, now())
+		ON CONFLICT (worker_id) DO UPDATE
+		SET seen_at = now()
+	), expired_instances AS (
+		DELETE FROM worker_instances
+		WHERE seen_at < now() - $2::INTERVAL AND worker_id <> $1
+	)
+	SELECT count(*) + 1
+	FROM worker_instances
+	WHERE seen_at >= now() - $2::INTERVAL AND worker_id <> $1`
+
+func (s *Storage) TouchWorkerInstance(ctx context.Context, workerID string, activeWithin time.Duration) (int, error) {
+	var instances int
+	if err := pgxscan.Get(ctx, s.pool, &instances, touchWorkerInstanceQuery, workerID, activeWithin); err != nil {
+		return 0, errors.Wrap(err, "pgxscan get")
+	}
+	return instances, nil
+}
+
+const removeWorkerInstanceQuery = `DELETE FROM worker_instances WHERE worker_id = $1`
+
+func (s *Storage) RemoveWorkerInstance(ctx context.Context, workerID string) error {
+	if _, err := s.pool.Exec(ctx, removeWorkerInstanceQuery, workerID); err != nil {
+		return errors.Wrap(err, "exec query")
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package schedule
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,25 +15,77 @@ import (
 )
 
 type scheduleService interface {
-	ScheduleJob(ctx context.Context, job Job) error
+	ScheduleJob(ctx context.Context, job Job) (int64, bool, error)
+	GetJob(ctx context.Context, id int64) (Job, error)
+	GetJobs(ctx context.Context, filter JobsFilter) ([]Job, error)
+	GetJobEvents(ctx context.Context, jobID int64) ([]JobEvent, error)
+	CancelJob(ctx context.Context, id int64) (Job, error)
+	GetDeadJobs(ctx context.Context, queueID string, limit int) ([]Job, error)
+	ReplayDeadJobs(ctx context.Context, queueID string, jobIDs []int64, dateTime *time.Time) ([]int64, error)
+	UpsertRecurringJob(ctx context.Context, job RecurringJob) error
+	GetRecurringJobs(ctx context.Context, queueID string) ([]RecurringJob, error)
+	DeleteRecurringJob(ctx context.Context, name string) error
+	UpdateQueueSettings(ctx context.Context, queueID string, settings QueueSettings) (Queue, error)
+	SetQueueState(ctx context.Context, queueID string, state string) (Queue, error)
+	GetQueuesStats(ctx context.Context) ([]QueueStats, error)
 }
 
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+const (
+	defaultMaxPayloadSize = 64 * 1024
+	defaultListLimit      = 100
+	maxListLimit          = 1000
+)
+
 type Handler struct {
 	scheduleService scheduleService
+	maxPayloadSize  int
 }
 
-func NewHandler(scheduleService scheduleService) *Handler {
-	return &Handler{scheduleService: scheduleService}
+func NewHandler(scheduleService scheduleService, maxPayloadSize int) *Handler {
+	if maxPayloadSize == 0 {
+		maxPayloadSize = defaultMaxPayloadSize
+	}
+	return &Handler{
+		scheduleService: scheduleService,
+		maxPayloadSize:  maxPayloadSize,
+	}
 }
 
 func (h *Handler) RegisterFastHTTPRouters(a fiber.Router) {
 	a.Post("/api/v1/schedule-job", h.scheduleJob)
+	a.Get("/api/v1/jobs", h.getJobs)
+	a.Get("/api/v1/jobs/:id", h.getJob)
+	a.Get("/api/v1/jobs/:id/events", h.getJobEvents)
+	a.Delete("/api/v1/jobs/:id", h.cancelJob)
+	a.Post("/api/v1/jobs/:id/cancel", h.cancelJob)
+	a.Get("/api/v1/queues/:queue_id/dead-jobs", h.getDeadJobs)
+	a.Post("/api/v1/queues/:queue_id/dead-jobs/replay", h.replayDeadJobs)
+	a.Get("/api/v1/queues", h.getQueues)
+	a.Patch("/api/v1/queues/:queue_id", h.updateQueueSettings)
+	a.Post("/api/v1/queues/:queue_id/pause", h.setQueueState(QueueStatePaused))
+	a.Post("/api/v1/queues/:queue_id/resume", h.setQueueState(QueueStateReady))
+	a.Post("/api/v1/queues/:queue_id/drain", h.setQueueState(QueueStateDraining))
+	a.Post("/api/v1/recurring-jobs", h.upsertRecurringJob)
+	a.Get("/api/v1/recurring-jobs", h.getRecurringJobs)
+	a.Delete("/api/v1/recurring-jobs/:name", h.deleteRecurringJob)
 }
 
 type scheduleJobArgs struct {
-	Timestamp int64  `json:"timestamp"`
-	QueueID   string `json:"queue_id"`
-	Action    string `json:"action"`
+	Timestamp int64           `json:"timestamp"`
+	QueueID   string          `json:"queue_id"`
+	Action    string          `json:"action"`
+	Payload   json.RawMessage `json:"payload"`
+	Priority  int             `json:"priority"`
+	TimeoutMS int64           `json:"timeout_ms"`
+
+	MaxAttempts    int    `json:"max_attempts"`
+	IdempotencyKey string `json:"idempotency_key"`
 }
 
 func (h *Handler) scheduleJob(c *fiber.Ctx) error {
@@ -42,14 +100,659 @@ func (h *Handler) scheduleJob(c *fiber.Ctx) error {
 	if args.Action == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "missing action field")
 	}
+	if args.MaxAttempts < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid max_attempts field: must not be negative")
+	}
+	if args.Priority < MinPriority || args.Priority > MaxPriority {
+		return fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("invalid priority field: must be between %d and %d", MinPriority, MaxPriority))
+	}
+	if args.TimeoutMS < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid timeout_ms field: must not be negative")
+	}
+	var timeout *time.Duration
+	if args.TimeoutMS > 0 {
+		t := time.Duration(args.TimeoutMS) * time.Millisecond
+		timeout = &t
+	}
+	payload, err := h.validatePayload(args.Payload)
+	if err != nil {
+		return err
+	}
+	idempotencyKey, err := parseIdempotencyKey(c, args.IdempotencyKey)
+	if err != nil {
+		return err
+	}
 
-	if err := h.scheduleService.ScheduleJob(c.UserContext(), Job{
+	id, created, err := h.scheduleService.ScheduleJob(c.UserContext(), Job{
 		DateTime: time.Unix(args.Timestamp, 0),
 		QueueID:  args.QueueID,
 		Action:   args.Action,
-	}); err != nil {
+		Payload:  payload,
+		Priority: args.Priority,
+		Timeout:  timeout,
+
+		MaxAttempts:    args.MaxAttempts,
+		IdempotencyKey: idempotencyKey,
+	})
+	if errors.Is(err, ErrQueueDraining) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
 		return errors.Wrap(err, "schedule job")
 	}
 
+	if !created {
+		c.Set(idempotentReplayedHeader, "true")
+	}
+	return c.JSON(scheduleJobResponse{ID: id})
+}
+
+type scheduleJobResponse struct {
+	ID int64 `json:"id"`
+}
+
+// parseIdempotencyKey takes the key either from the body field or from the header.
+// Both are allowed only when they are equal.
+func parseIdempotencyKey(c *fiber.Ctx, bodyKey string) (string, error) {
+	key := bodyKey
+	if headerKey := c.Get(idempotencyKeyHeader); headerKey != "" {
+		if key != "" && key != headerKey {
+			return "", fiber.NewError(fiber.StatusBadRequest,
+				"idempotency_key field doesn't match "+idempotencyKeyHeader+" header")
+		}
+		key = headerKey
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return "", fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("invalid idempotency key: must not be longer than %d", maxIdempotencyKeyLength))
+	}
+	return key, nil
+}
+
+func (h *Handler) validatePayload(payload json.RawMessage) (json.RawMessage, error) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		return nil, nil
+	}
+	if len(payload) > h.maxPayloadSize {
+		return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge,
+			fmt.Sprintf("payload size = %d exceeds limit = %d bytes", len(payload), h.maxPayloadSize))
+	}
+	if !json.Valid(payload) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid payload field: malformed json")
+	}
+	return payload, nil
+}
+
+type jobResponse struct {
+	ID        int64           `json:"id"`
+	QueueID   string          `json:"queue_id"`
+	Timestamp int64           `json:"timestamp"`
+	Action    string          `json:"action"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	State     string          `json:"state"`
+	WorkerID  string          `json:"worker_id,omitempty"`
+	Priority  int             `json:"priority"`
+	TimeoutMS *int64          `json:"timeout_ms,omitempty"`
+
+	Attempts      int     `json:"attempts"`
+	MaxAttempts   int     `json:"max_attempts"`
+	LastError     *string `json:"last_error,omitempty"`
+	LastErrorKind *string `json:"last_error_kind,omitempty"`
+	FailedAt      *int64  `json:"failed_at,omitempty"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func newJobResponse(job Job) jobResponse {
+	resp := jobResponse{
+		ID:        job.ID,
+		QueueID:   job.QueueID,
+		Timestamp: job.DateTime.Unix(),
+		Action:    job.Action,
+		Payload:   job.Payload,
+		State:     job.State,
+		WorkerID:  job.WorkerID,
+		Priority:  job.Priority,
+
+		Attempts:      job.Attempts,
+		MaxAttempts:   job.MaxAttempts,
+		LastError:     job.LastError,
+		LastErrorKind: job.LastErrorKind,
+
+		IdempotencyKey: job.IdempotencyKey,
+	}
+	if bytes.Equal(resp.Payload, []byte("null")) {
+		resp.Payload = nil
+	}
+	if job.FailedAt != nil {
+		failedAt := job.FailedAt.Unix()
+		resp.FailedAt = &failedAt
+	}
+	if job.Timeout != nil {
+		timeoutMS := job.Timeout.Milliseconds()
+		resp.TimeoutMS = &timeoutMS
+	}
+	return resp
+}
+
+func (h *Handler) getJob(c *fiber.Ctx) error {
+	id, err := parseJobID(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.scheduleService.GetJob(c.UserContext(), id)
+	if errors.Is(err, ErrJobNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return errors.Wrap(err, "get job")
+	}
+	return c.JSON(newJobResponse(job))
+}
+
+type jobEventResponse struct {
+	Type       string  `json:"type"`
+	State      string  `json:"state"`
+	WorkerID   string  `json:"worker_id,omitempty"`
+	LeaseToken int64   `json:"lease_token"`
+	Error      *string `json:"error,omitempty"`
+	Timestamp  int64   `json:"timestamp"`
+}
+
+type getJobEventsResponse struct {
+	Events []jobEventResponse `json:"events"`
+}
+
+func (h *Handler) getJobEvents(c *fiber.Ctx) error {
+	id, err := parseJobID(c)
+	if err != nil {
+		return err
+	}
+
+	events, err := h.scheduleService.GetJobEvents(c.UserContext(), id)
+	if errors.Is(err, ErrJobNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return errors.Wrap(err, "get job events")
+	}
+
+	resp := getJobEventsResponse{Events: make([]jobEventResponse, 0, len(events))}
+	for i := range events {
+		resp.Events = append(resp.Events, jobEventResponse{
+			Type:       events[i].Type,
+			State:      events[i].State,
+			WorkerID:   events[i].WorkerID,
+			LeaseToken: events[i].LeaseToken,
+			Error:      events[i].Error,
+			Timestamp:  events[i].CreatedAt.Unix(),
+		})
+	}
+	return c.JSON(resp)
+}
+
+type getJobsResponse struct {
+	Jobs       []jobResponse `json:"jobs"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func (h *Handler) getJobs(c *fiber.Ctx) error {
+	filter, err := parseJobsFilter(c)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := h.scheduleService.GetJobs(c.UserContext(), filter)
+	if err != nil {
+		return errors.Wrap(err, "get jobs")
+	}
+
+	resp := getJobsResponse{Jobs: make([]jobResponse, 0, len(jobs))}
+	for i := range jobs {
+		resp.Jobs = append(resp.Jobs, newJobResponse(jobs[i]))
+	}
+	if len(jobs) == filter.Limit {
+		last := jobs[len(jobs)-1]
+		resp.NextCursor = encodeJobsCursor(JobsCursor{ID: last.ID, DateTime: last.DateTime})
+	}
+	return c.JSON(resp)
+}
+
+func (h *Handler) cancelJob(c *fiber.Ctx) error {
+	id, err := parseJobID(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.scheduleService.CancelJob(c.UserContext(), id)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, ErrJobNotCancellable):
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("%s: job is in %s state", err, job.State))
+	case err != nil:
+		return errors.Wrap(err, "cancel job")
+	}
+	return c.JSON(newJobResponse(job))
+}
+
+type getDeadJobsResponse struct {
+	Jobs []jobResponse `json:"jobs"`
+}
+
+func (h *Handler) getDeadJobs(c *fiber.Ctx) error {
+	limit, err := parseLimit(c)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := h.scheduleService.GetDeadJobs(c.UserContext(), c.Params("queue_id"), limit)
+	if err != nil {
+		return errors.Wrap(err, "get dead jobs")
+	}
+
+	resp := getDeadJobsResponse{Jobs: make([]jobResponse, 0, len(jobs))}
+	for i := range jobs {
+		resp.Jobs = append(resp.Jobs, newJobResponse(jobs[i]))
+	}
+	return c.JSON(resp)
+}
+
+type replayDeadJobsArgs struct {
+	JobIDs    []int64 `json:"job_ids"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+type replayDeadJobsResponse struct {
+	JobIDs []int64 `json:"job_ids"`
+}
+
+func (h *Handler) replayDeadJobs(c *fiber.Ctx) error {
+	var args replayDeadJobsArgs
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&args); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+
+	var dateTime *time.Time
+	if args.Timestamp != 0 {
+		t := time.Unix(args.Timestamp, 0)
+		dateTime = &t
+	}
+
+	ids, err := h.scheduleService.ReplayDeadJobs(c.UserContext(), c.Params("queue_id"), args.JobIDs, dateTime)
+	if errors.Is(err, ErrQueueDraining) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return errors.Wrap(err, "replay dead jobs")
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+	return c.JSON(replayDeadJobsResponse{JobIDs: ids})
+}
+
+type upsertRecurringJobArgs struct {
+	Name           string          `json:"name"`
+	QueueID        string          `json:"queue_id"`
+	CronExpression string          `json:"cron_expression"`
+	Timezone       string          `json:"timezone"`
+	Action         string          `json:"action"`
+	Payload        json.RawMessage `json:"payload"`
+	MaxAttempts    int             `json:"max_attempts"`
+	CatchUpPolicy  string          `json:"catch_up_policy"`
+}
+
+func (h *Handler) upsertRecurringJob(c *fiber.Ctx) error {
+	var args upsertRecurringJobArgs
+	if err := c.BodyParser(&args); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if args.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing name field")
+	}
+	if args.CronExpression == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing cron_expression field")
+	}
+	if args.Action == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing action field")
+	}
+	if args.MaxAttempts < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid max_attempts field: must not be negative")
+	}
+	payload, err := h.validatePayload(args.Payload)
+	if err != nil {
+		return err
+	}
+
+	err = h.scheduleService.UpsertRecurringJob(c.UserContext(), RecurringJob{
+		Name:           args.Name,
+		QueueID:        args.QueueID,
+		CronExpression: args.CronExpression,
+		Timezone:       args.Timezone,
+		Action:         args.Action,
+		Payload:        payload,
+		MaxAttempts:    args.MaxAttempts,
+		CatchUpPolicy:  args.CatchUpPolicy,
+	})
+	if errors.Is(err, ErrInvalidRecurringJob) {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return errors.Wrap(err, "upsert recurring job")
+	}
+
 	return nil
 }
+
+type recurringJobResponse struct {
+	Name           string          `json:"name"`
+	QueueID        string          `json:"queue_id"`
+	CronExpression string          `json:"cron_expression"`
+	Timezone       string          `json:"timezone"`
+	Action         string          `json:"action"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	MaxAttempts    int             `json:"max_attempts"`
+	CatchUpPolicy  string          `json:"catch_up_policy"`
+	NextRunAt      *int64          `json:"next_run_at,omitempty"`
+}
+
+type getRecurringJobsResponse struct {
+	RecurringJobs []recurringJobResponse `json:"recurring_jobs"`
+}
+
+func (h *Handler) getRecurringJobs(c *fiber.Ctx) error {
+	jobs, err := h.scheduleService.GetRecurringJobs(c.UserContext(), c.Query("queue_id"))
+	if err != nil {
+		return errors.Wrap(err, "get recurring jobs")
+	}
+
+	resp := getRecurringJobsResponse{RecurringJobs: make([]recurringJobResponse, 0, len(jobs))}
+	for i := range jobs {
+		job := recurringJobResponse{
+			Name:           jobs[i].Name,
+			QueueID:        jobs[i].QueueID,
+			CronExpression: jobs[i].CronExpression,
+			Timezone:       jobs[i].Timezone,
+			Action:         jobs[i].Action,
+			Payload:        jobs[i].Payload,
+			MaxAttempts:    jobs[i].MaxAttempts,
+			CatchUpPolicy:  jobs[i].CatchUpPolicy,
+		}
+		if bytes.Equal(job.Payload, []byte("null")) {
+			job.Payload = nil
+		}
+		if jobs[i].NextRunAt != nil {
+			nextRunAt := jobs[i].NextRunAt.Unix()
+			job.NextRunAt = &nextRunAt
+		}
+		resp.RecurringJobs = append(resp.RecurringJobs, job)
+	}
+	return c.JSON(resp)
+}
+
+func (h *Handler) deleteRecurringJob(c *fiber.Ctx) error {
+	err := h.scheduleService.DeleteRecurringJob(c.UserContext(), c.Params("name"))
+	if errors.Is(err, ErrRecurringJobNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return errors.Wrap(err, "delete recurring job")
+}
+
+func parseJobID(c *fiber.Ctx) (int64, error) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid job id")
+	}
+	return id, nil
+}
+
+func parseJobsFilter(c *fiber.Ctx) (JobsFilter, error) {
+	limit, err := parseLimit(c)
+	if err != nil {
+		return JobsFilter{}, err
+	}
+	filter := JobsFilter{
+		QueueID: c.Query("queue_id"),
+		SortBy:  c.Query("sort", "id"),
+		Limit:   limit,
+	}
+
+	if states := c.Query("state"); states != "" {
+		filter.States = strings.Split(states, ",")
+	}
+	if filter.From, err = parseTimestampQuery(c, "from"); err != nil {
+		return JobsFilter{}, err
+	}
+	if filter.To, err = parseTimestampQuery(c, "to"); err != nil {
+		return JobsFilter{}, err
+	}
+
+	switch filter.SortBy {
+	case "id", "date_time":
+	default:
+		return JobsFilter{}, fiber.NewError(fiber.StatusBadRequest, "invalid sort param: must be id or date_time")
+	}
+	switch c.Query("order", "asc") {
+	case "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return JobsFilter{}, fiber.NewError(fiber.StatusBadRequest, "invalid order param: must be asc or desc")
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		jobsCursor, err := decodeJobsCursor(cursor)
+		if err != nil {
+			return JobsFilter{}, fiber.NewError(fiber.StatusBadRequest, "invalid cursor param")
+		}
+		filter.Cursor = &jobsCursor
+	}
+	return filter, nil
+}
+
+func parseTimestampQuery(c *fiber.Ctx, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid %s param: must be unix timestamp", key))
+	}
+	t := time.Unix(timestamp, 0)
+	return &t, nil
+}
+
+type jobsCursor struct {
+	ID       int64 `json:"id"`
+	DateTime int64 `json:"date_time"`
+}
+
+func encodeJobsCursor(cursor JobsCursor) string {
+	data, _ := json.Marshal(jobsCursor{ID: cursor.ID, DateTime: cursor.DateTime.UnixNano()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJobsCursor(cursor string) (JobsCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return JobsCursor{}, errors.Wrap(err, "decode base64")
+	}
+	var value jobsCursor
+	if err = json.Unmarshal(data, &value); err != nil {
+		return JobsCursor{}, errors.Wrap(err, "unmarshal json")
+	}
+	return JobsCursor{ID: value.ID, DateTime: time.Unix(0, value.DateTime)}, nil
+}
+
+func parseLimit(c *fiber.Ctx) (int, error) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return defaultListLimit, nil
+	}
+	value, err := strconv.Atoi(limit)
+	if err != nil || value <= 0 || value > maxListLimit {
+		return 0, fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("invalid limit param: must be in range [1, %d]", maxListLimit))
+	}
+	return value, nil
+}
+
+type queueResponse struct {
+	QueueID        string `json:"queue_id"`
+	State          string `json:"state"`
+	MaxConcurrency int    `json:"max_concurrency"`
+	Running        int    `json:"running"`
+	RateLimit      *int   `json:"rate_limit,omitempty"`
+	RateIntervalMS *int64 `json:"rate_interval_ms,omitempty"`
+	JobTimeoutMS   *int64 `json:"job_timeout_ms,omitempty"`
+	LeaseMS        *int64 `json:"lease_ms,omitempty"`
+	RetentionMS    *int64 `json:"retention_ms,omitempty"`
+}
+
+func newQueueResponse(queue Queue) queueResponse {
+	resp := queueResponse{
+		QueueID:        queue.QueueID,
+		State:          queue.State,
+		MaxConcurrency: queue.MaxConcurrency,
+		Running:        queue.Running,
+		RateLimit:      queue.RateLimit,
+	}
+	if queue.RateLimit != nil {
+		rateIntervalMS := queue.RateInterval.Milliseconds()
+		resp.RateIntervalMS = &rateIntervalMS
+	}
+	if queue.JobTimeout != nil {
+		jobTimeoutMS := queue.JobTimeout.Milliseconds()
+		resp.JobTimeoutMS = &jobTimeoutMS
+	}
+	if queue.LeaseDuration != nil {
+		leaseMS := queue.LeaseDuration.Milliseconds()
+		resp.LeaseMS = &leaseMS
+	}
+	if queue.Retention != nil {
+		retentionMS := queue.Retention.Milliseconds()
+		resp.RetentionMS = &retentionMS
+	}
+	return resp
+}
+
+// updateQueueSettingsArgs holds the settings to change, missing fields are left as they are.
+// Zero rate_limit removes the rate limit, zero job_timeout_ms removes the job timeout,
+// zero lease_ms brings back the default lease of the checker, and zero retention_ms brings back
+// the default retention. A non-zero lease_ms must be at least MinLeaseDuration.
+type updateQueueSettingsArgs struct {
+	MaxConcurrency *int   `json:"max_concurrency"`
+	RateLimit      *int   `json:"rate_limit"`
+	RateIntervalMS *int64 `json:"rate_interval_ms"`
+	JobTimeoutMS   *int64 `json:"job_timeout_ms"`
+	LeaseMS        *int64 `json:"lease_ms"`
+	RetentionMS    *int64 `json:"retention_ms"`
+}
+
+func (h *Handler) updateQueueSettings(c *fiber.Ctx) error {
+	var args updateQueueSettingsArgs
+	if err := c.BodyParser(&args); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if args.MaxConcurrency != nil && *args.MaxConcurrency <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid max_concurrency field: must be positive")
+	}
+	if args.RateLimit != nil && *args.RateLimit < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid rate_limit field: must not be negative")
+	}
+	var rateInterval *time.Duration
+	if args.RateIntervalMS != nil {
+		if *args.RateIntervalMS <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid rate_interval_ms field: must be positive")
+		}
+		interval := time.Duration(*args.RateIntervalMS) * time.Millisecond
+		rateInterval = &interval
+	}
+	var jobTimeout *time.Duration
+	if args.JobTimeoutMS != nil {
+		if *args.JobTimeoutMS < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid job_timeout_ms field: must not be negative")
+		}
+		timeout := time.Duration(*args.JobTimeoutMS) * time.Millisecond
+		jobTimeout = &timeout
+	}
+	var leaseDuration *time.Duration
+	if args.LeaseMS != nil {
+		lease := time.Duration(*args.LeaseMS) * time.Millisecond
+		if lease != 0 && lease < MinLeaseDuration {
+			return fiber.NewError(fiber.StatusBadRequest,
+				fmt.Sprintf("invalid lease_ms field: must be zero or at least %d", MinLeaseDuration.Milliseconds()))
+		}
+		leaseDuration = &lease
+	}
+	var retention *time.Duration
+	if args.RetentionMS != nil {
+		if *args.RetentionMS < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid retention_ms field: must not be negative")
+		}
+		value := time.Duration(*args.RetentionMS) * time.Millisecond
+		retention = &value
+	}
+
+	queue, err := h.scheduleService.UpdateQueueSettings(c.UserContext(), c.Params("queue_id"), QueueSettings{
+		MaxConcurrency: args.MaxConcurrency,
+		RateLimit:      args.RateLimit,
+		RateInterval:   rateInterval,
+		JobTimeout:     jobTimeout,
+		LeaseDuration:  leaseDuration,
+		Retention:      retention,
+	})
+	if err != nil {
+		return errors.Wrap(err, "update queue settings")
+	}
+	return c.JSON(newQueueResponse(queue))
+}
+
+func (h *Handler) setQueueState(state string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		queue, err := h.scheduleService.SetQueueState(c.UserContext(), c.Params("queue_id"), state)
+		if errors.Is(err, ErrQueueNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return errors.Wrap(err, "set queue state")
+		}
+		return c.JSON(newQueueResponse(queue))
+	}
+}
+
+type queueStatsResponse struct {
+	queueResponse
+	Backlog    int `json:"backlog"`
+	DueBacklog int `json:"due_backlog"`
+}
+
+type getQueuesResponse struct {
+	Queues []queueStatsResponse `json:"queues"`
+}
+
+func (h *Handler) getQueues(c *fiber.Ctx) error {
+	stats, err := h.scheduleService.GetQueuesStats(c.UserContext())
+	if err != nil {
+		return errors.Wrap(err, "get queues stats")
+	}
+
+	resp := getQueuesResponse{Queues: make([]queueStatsResponse, 0, len(stats))}
+	for i := range stats {
+		resp.Queues = append(resp.Queues, queueStatsResponse{
+			queueResponse: newQueueResponse(stats[i].Queue),
+			Backlog:       stats[i].Backlog,
+			DueBacklog:    stats[i].DueBacklog,
+		})
+	}
+	return c.JSON(resp)
+}
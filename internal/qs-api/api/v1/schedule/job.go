@@ -1,9 +1,59 @@
 package schedule
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+)
+
+const (
+	MinPriority = schedule.MinPriority
+	MaxPriority = schedule.MaxPriority
+)
 
 type Job struct {
+	ID       int64
 	QueueID  string
 	DateTime time.Time
 	Action   string
+	Payload  json.RawMessage
+	State    string
+	WorkerID string
+	Priority int
+	Timeout  *time.Duration
+
+	Attempts      int
+	MaxAttempts   int
+	LastError     *string
+	LastErrorKind *string
+	FailedAt      *time.Time
+
+	IdempotencyKey string
+}
+
+type JobEvent struct {
+	Type       string
+	State      string
+	WorkerID   string
+	LeaseToken int64
+	Error      *string
+	CreatedAt  time.Time
+}
+
+type JobsFilter struct {
+	QueueID string
+	States  []string
+	From    *time.Time
+	To      *time.Time
+	SortBy  string
+	Desc    bool
+	Cursor  *JobsCursor
+	Limit   int
+}
+
+// JobsCursor points at the last job of the previous page.
+type JobsCursor struct {
+	ID       int64
+	DateTime time.Time
 }
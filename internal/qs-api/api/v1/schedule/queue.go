@@ -0,0 +1,42 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+)
+
+const (
+	QueueStateReady    = schedule.QueueStateReady
+	QueueStatePaused   = schedule.QueueStatePaused
+	QueueStateDraining = schedule.QueueStateDraining
+)
+
+const MinLeaseDuration = schedule.MinLeaseDuration
+
+type Queue struct {
+	QueueID        string
+	State          string
+	MaxConcurrency int
+	Running        int
+	RateLimit      *int
+	RateInterval   time.Duration
+	JobTimeout     *time.Duration
+	LeaseDuration  *time.Duration
+	Retention      *time.Duration
+}
+
+type QueueSettings struct {
+	MaxConcurrency *int
+	RateLimit      *int
+	RateInterval   *time.Duration
+	JobTimeout     *time.Duration
+	LeaseDuration  *time.Duration
+	Retention      *time.Duration
+}
+
+type QueueStats struct {
+	Queue
+	Backlog    int
+	DueBacklog int
+}
@@ -0,0 +1,18 @@
+package schedule
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RecurringJob struct {
+	Name           string
+	QueueID        string
+	CronExpression string
+	Timezone       string
+	Action         string
+	Payload        json.RawMessage
+	MaxAttempts    int
+	CatchUpPolicy  string
+	NextRunAt      *time.Time
+}
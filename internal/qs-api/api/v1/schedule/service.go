@@ -2,27 +2,315 @@ package schedule
 
 import (
 	"context"
+	"time"
 
 	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
 	"github.com/pkg/errors"
 )
 
 type scheduleStorage interface {
-	InsertJob(ctx context.Context, job schedule.Job) error
+	InsertJob(ctx context.Context, job schedule.Job) (int64, error)
+	InsertJobIdempotent(ctx context.Context,
+		job schedule.Job, idempotencyKey string, ttl time.Duration) (int64, bool, error)
+	GetJob(ctx context.Context, id int64) (schedule.Job, error)
+	GetJobs(ctx context.Context, filter schedule.JobsFilter) ([]schedule.Job, error)
+	GetJobEvents(ctx context.Context, jobID int64) ([]schedule.JobEvent, error)
+	CancelJob(ctx context.Context, id int64) (schedule.Job, error)
+	GetDeadJobs(ctx context.Context, queueID string, limit int) ([]schedule.Job, error)
+	ReplayDeadJobs(ctx context.Context, queueID string, jobIDs []int64, dateTime *time.Time) ([]int64, error)
+	UpsertRecurringJob(ctx context.Context, job schedule.RecurringJob) (int64, error)
+	GetRecurringJobs(ctx context.Context, queueID string) ([]schedule.RecurringJob, error)
+	DeleteRecurringJob(ctx context.Context, name string) error
+	UpdateQueueSettings(ctx context.Context, queueID string, settings schedule.QueueSettings) (schedule.Queue, error)
+	SetQueueState(ctx context.Context, queueID string, state schedule.QueueState) (schedule.Queue, error)
+	GetQueuesStats(ctx context.Context) ([]schedule.QueueStats, error)
 }
 
+var (
+	ErrJobNotFound          = errors.New("job not found")
+	ErrJobNotCancellable    = errors.New("job is not cancellable")
+	ErrInvalidRecurringJob  = errors.New("invalid recurring job")
+	ErrRecurringJobNotFound = errors.New("recurring job not found")
+	ErrQueueNotFound        = errors.New("queue not found")
+	ErrQueueDraining        = errors.New("queue is draining, new jobs are rejected")
+)
+
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
 type Service struct {
-	scheduleStorage scheduleStorage
+	scheduleStorage   scheduleStorage
+	idempotencyKeyTTL time.Duration
 }
 
-func NewService(scheduleStorage scheduleStorage) *Service {
-	return &Service{scheduleStorage: scheduleStorage}
+func NewService(scheduleStorage scheduleStorage, idempotencyKeyTTL time.Duration) *Service {
+	if idempotencyKeyTTL == 0 {
+		idempotencyKeyTTL = defaultIdempotencyKeyTTL
+	}
+	return &Service{
+		scheduleStorage:   scheduleStorage,
+		idempotencyKeyTTL: idempotencyKeyTTL,
+	}
 }
 
-func (s *Service) ScheduleJob(ctx context.Context, job Job) error {
-	return errors.Wrap(s.scheduleStorage.InsertJob(ctx, schedule.Job{
+// ScheduleJob inserts the job and returns its id. A job with an idempotency key is inserted only once
+// per queue within the key ttl, and repeated calls return the id of the original job with created = false.
+func (s *Service) ScheduleJob(ctx context.Context, job Job) (id int64, created bool, err error) {
+	storageJob := schedule.Job{
 		Queue:    schedule.Queue{QueueID: job.QueueID},
 		DateTime: job.DateTime,
 		Action:   job.Action,
-	}), "insert job into storage")
+		Payload:  job.Payload,
+		Priority: job.Priority,
+		Timeout:  job.Timeout,
+
+		MaxAttempts: job.MaxAttempts,
+	}
+	if job.IdempotencyKey == "" {
+		id, err = s.scheduleStorage.InsertJob(ctx, storageJob)
+		if errors.Is(err, schedule.ErrQueueDraining) {
+			return 0, false, ErrQueueDraining
+		}
+		return id, err == nil, errors.Wrap(err, "insert job into storage")
+	}
+	id, created, err = s.scheduleStorage.InsertJobIdempotent(ctx, storageJob, job.IdempotencyKey, s.idempotencyKeyTTL)
+	if errors.Is(err, schedule.ErrQueueDraining) {
+		return 0, false, ErrQueueDraining
+	}
+	return id, created, errors.Wrap(err, "insert idempotent job into storage")
+}
+
+func (s *Service) GetJob(ctx context.Context, id int64) (Job, error) {
+	job, err := s.scheduleStorage.GetJob(ctx, id)
+	if errors.Is(err, schedule.ErrJobNotFound) {
+		return Job{}, ErrJobNotFound
+	}
+	if err != nil {
+		return Job{}, errors.Wrap(err, "get job from storage")
+	}
+	return jobFromStorage(job), nil
+}
+
+func (s *Service) GetJobs(ctx context.Context, filter JobsFilter) ([]Job, error) {
+	storageFilter := schedule.JobsFilter{
+		QueueID: filter.QueueID,
+		States:  filter.States,
+		From:    filter.From,
+		To:      filter.To,
+		SortBy:  filter.SortBy,
+		Desc:    filter.Desc,
+		Limit:   filter.Limit,
+	}
+	if filter.Cursor != nil {
+		storageFilter.AfterID = filter.Cursor.ID
+		storageFilter.AfterDateTime = &filter.Cursor.DateTime
+	}
+
+	jobs, err := s.scheduleStorage.GetJobs(ctx, storageFilter)
+	if err != nil {
+		return nil, errors.Wrap(err, "get jobs from storage")
+	}
+	result := make([]Job, 0, len(jobs))
+	for i := range jobs {
+		result = append(result, jobFromStorage(jobs[i]))
+	}
+	return result, nil
+}
+
+// GetJobEvents returns the timeline of the job, which is empty for jobs created before events were recorded.
+func (s *Service) GetJobEvents(ctx context.Context, jobID int64) ([]JobEvent, error) {
+	if _, err := s.GetJob(ctx, jobID); err != nil {
+		return nil, err
+	}
+	events, err := s.scheduleStorage.GetJobEvents(ctx, jobID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get job events from storage")
+	}
+	result := make([]JobEvent, 0, len(events))
+	for i := range events {
+		event := JobEvent{
+			Type:       events[i].Type,
+			State:      events[i].State,
+			LeaseToken: events[i].LeaseToken,
+			Error:      events[i].Error,
+			CreatedAt:  events[i].CreatedAt,
+		}
+		if events[i].WorkerID != nil {
+			event.WorkerID = *events[i].WorkerID
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+func (s *Service) CancelJob(ctx context.Context, id int64) (Job, error) {
+	job, err := s.scheduleStorage.CancelJob(ctx, id)
+	switch {
+	case errors.Is(err, schedule.ErrJobNotFound):
+		return Job{}, ErrJobNotFound
+	case errors.Is(err, schedule.ErrJobNotCancellable):
+		return jobFromStorage(job), ErrJobNotCancellable
+	case err != nil:
+		return Job{}, errors.Wrap(err, "cancel job in storage")
+	}
+	return jobFromStorage(job), nil
+}
+
+func (s *Service) GetDeadJobs(ctx context.Context, queueID string, limit int) ([]Job, error) {
+	jobs, err := s.scheduleStorage.GetDeadJobs(ctx, queueID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "get dead jobs from storage")
+	}
+	result := make([]Job, 0, len(jobs))
+	for i := range jobs {
+		result = append(result, jobFromStorage(jobs[i]))
+	}
+	return result, nil
+}
+
+func (s *Service) ReplayDeadJobs(ctx context.Context,
+	queueID string, jobIDs []int64, dateTime *time.Time) ([]int64, error) {
+	ids, err := s.scheduleStorage.ReplayDeadJobs(ctx, queueID, jobIDs, dateTime)
+	if errors.Is(err, schedule.ErrQueueDraining) {
+		return nil, ErrQueueDraining
+	}
+	return ids, errors.Wrap(err, "replay dead jobs in storage")
+}
+
+func jobFromStorage(job schedule.Job) Job {
+	result := Job{
+		ID:       job.ID,
+		QueueID:  job.QueueID,
+		DateTime: job.DateTime,
+		Action:   job.Action,
+		Payload:  job.Payload,
+		State:    job.State,
+		Priority: job.Priority,
+		Timeout:  job.Timeout,
+
+		Attempts:      job.Attempts,
+		MaxAttempts:   job.MaxAttempts,
+		LastError:     job.LastError,
+		LastErrorKind: job.LastErrorKind,
+		FailedAt:      job.FailedAt,
+	}
+	if job.WorkerID != nil {
+		result.WorkerID = *job.WorkerID
+	}
+	if job.IdempotencyKey != nil {
+		result.IdempotencyKey = *job.IdempotencyKey
+	}
+	return result
+}
+
+func (s *Service) UpsertRecurringJob(ctx context.Context, job RecurringJob) error {
+	if _, err := schedule.ParseCronSchedule(job.CronExpression, job.Timezone); err != nil {
+		return errors.Wrap(ErrInvalidRecurringJob, err.Error())
+	}
+	switch job.CatchUpPolicy {
+	case "", schedule.CatchUpPolicySkip, schedule.CatchUpPolicyOnce, schedule.CatchUpPolicyAll:
+	default:
+		return errors.Wrapf(ErrInvalidRecurringJob, "unsupported catch up policy = %q", job.CatchUpPolicy)
+	}
+	timezone := job.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	_, err := s.scheduleStorage.UpsertRecurringJob(ctx, schedule.RecurringJob{
+		Name:           job.Name,
+		QueueID:        job.QueueID,
+		CronExpression: job.CronExpression,
+		Timezone:       timezone,
+		Action:         job.Action,
+		Payload:        job.Payload,
+		MaxAttempts:    job.MaxAttempts,
+		CatchUpPolicy:  job.CatchUpPolicy,
+	})
+	return errors.Wrap(err, "upsert recurring job into storage")
+}
+
+func (s *Service) GetRecurringJobs(ctx context.Context, queueID string) ([]RecurringJob, error) {
+	jobs, err := s.scheduleStorage.GetRecurringJobs(ctx, queueID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get recurring jobs from storage")
+	}
+	result := make([]RecurringJob, 0, len(jobs))
+	for i := range jobs {
+		result = append(result, RecurringJob{
+			Name:           jobs[i].Name,
+			QueueID:        jobs[i].QueueID,
+			CronExpression: jobs[i].CronExpression,
+			Timezone:       jobs[i].Timezone,
+			Action:         jobs[i].Action,
+			Payload:        jobs[i].Payload,
+			MaxAttempts:    jobs[i].MaxAttempts,
+			CatchUpPolicy:  jobs[i].CatchUpPolicy,
+			NextRunAt:      jobs[i].NextRunAt,
+		})
+	}
+	return result, nil
+}
+
+func (s *Service) DeleteRecurringJob(ctx context.Context, name string) error {
+	err := s.scheduleStorage.DeleteRecurringJob(ctx, name)
+	if errors.Is(err, schedule.ErrRecurringJobNotFound) {
+		return ErrRecurringJobNotFound
+	}
+	return errors.Wrap(err, "delete recurring job from storage")
+}
+
+func (s *Service) UpdateQueueSettings(ctx context.Context, queueID string, settings QueueSettings) (Queue, error) {
+	queue, err := s.scheduleStorage.UpdateQueueSettings(ctx, queueID, schedule.QueueSettings{
+		MaxConcurrency: settings.MaxConcurrency,
+		RateLimit:      settings.RateLimit,
+		RateInterval:   settings.RateInterval,
+		JobTimeout:     settings.JobTimeout,
+		LeaseDuration:  settings.LeaseDuration,
+		Retention:      settings.Retention,
+	})
+	if err != nil {
+		return Queue{}, errors.Wrap(err, "update queue settings in storage")
+	}
+	return queueFromStorage(queue), nil
+}
+
+func (s *Service) SetQueueState(ctx context.Context, queueID string, state string) (Queue, error) {
+	queue, err := s.scheduleStorage.SetQueueState(ctx, queueID, state)
+	if errors.Is(err, schedule.ErrQueueNotFound) {
+		return Queue{}, ErrQueueNotFound
+	}
+	if err != nil {
+		return Queue{}, errors.Wrap(err, "set queue state in storage")
+	}
+	return queueFromStorage(queue), nil
+}
+
+func (s *Service) GetQueuesStats(ctx context.Context) ([]QueueStats, error) {
+	stats, err := s.scheduleStorage.GetQueuesStats(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get queues stats from storage")
+	}
+	result := make([]QueueStats, 0, len(stats))
+	for i := range stats {
+		result = append(result, QueueStats{
+			Queue:      queueFromStorage(stats[i].Queue),
+			Backlog:    stats[i].Backlog,
+			DueBacklog: stats[i].DueBacklog,
+		})
+	}
+	return result, nil
+}
+
+func queueFromStorage(queue schedule.Queue) Queue {
+	return Queue{
+		QueueID:        queue.QueueID,
+		State:          queue.State,
+		MaxConcurrency: queue.MaxConcurrency,
+		Running:        queue.Running,
+		RateLimit:      queue.RateLimit,
+		RateInterval:   queue.RateInterval,
+		JobTimeout:     queue.JobTimeout,
+		LeaseDuration:  queue.LeaseDuration,
+		Retention:      queue.Retention,
+	}
 }
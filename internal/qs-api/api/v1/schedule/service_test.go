@@ -0,0 +1,44 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/pkg/errors"
+)
+
+type fakeStorage struct {
+	scheduleStorage
+
+	jobs map[int64]schedule.Job
+}
+
+func (f *fakeStorage) GetJob(_ context.Context, id int64) (schedule.Job, error) {
+	job, ok := f.jobs[id]
+	if !ok {
+		return schedule.Job{}, schedule.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (f *fakeStorage) GetJobEvents(context.Context, int64) ([]schedule.JobEvent, error) {
+	return nil, nil
+}
+
+func TestGetJobEvents(t *testing.T) {
+	s := NewService(&fakeStorage{jobs: map[int64]schedule.Job{1: {ID: 1}}}, 0)
+
+	// jobs created before events were recorded have an empty timeline
+	events, err := s.GetJobEvents(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("get events of a job without events: %v", err)
+	}
+	if events == nil || len(events) != 0 {
+		t.Errorf("events = %v, want an empty timeline", events)
+	}
+
+	if _, err = s.GetJobEvents(context.Background(), 2); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("get events of a missing job: err = %v, want %v", err, ErrJobNotFound)
+	}
+}
@@ -6,40 +6,83 @@ import (
 	"time"
 
 	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
 const (
 	defaultCheckDuration = 1 * time.Minute
-	maxRunningDuration   = 5 * time.Minute
+	defaultLeaseDuration = 5 * time.Minute
+	defaultMaxRecoveries = 3
 )
 
 type scheduleStorage interface {
-	GetRunningJobsForTooLong(ctx context.Context, dateTime time.Time) ([]schedule.Job, error)
-	RenewJob(ctx context.Context, job schedule.Job) error
+	GetStuckJobs(ctx context.Context, defaultLeaseDuration time.Duration) ([]schedule.StuckJob, error)
+	RecoverStuckJob(ctx context.Context, job schedule.Job, action schedule.StuckJobAction, reason string) (bool, error)
+}
+
+type leader interface {
+	IsLeader() bool
+}
+
+const stuckJobReason = "job was running for too long without heart beat"
+
+type Config struct {
+	CheckDuration time.Duration
+	// LeaseDuration is how long a running job may go without a heart beat when its queue has no lease of its own.
+	LeaseDuration  time.Duration
+	StuckJobAction schedule.StuckJobAction
+	// MaxRecoveries is how many times a stuck job is requeued before it's moved into the dead state.
+	MaxRecoveries int
 }
 
 type Service struct {
 	logger          *zap.Logger
 	scheduleStorage scheduleStorage
+	leader          leader
 	checkDuration   time.Duration
+	leaseDuration   time.Duration
+	stuckJobAction  schedule.StuckJobAction
+	maxRecoveries   int
 	doneChan        chan struct{}
 }
 
-func NewService(logger *zap.Logger, scheduleStorage scheduleStorage, checkDuration time.Duration) *Service {
-	if checkDuration == 0 {
-		checkDuration = defaultCheckDuration
+func NewService(logger *zap.Logger, scheduleStorage scheduleStorage, leader leader, cfg Config) *Service {
+	if cfg.CheckDuration == 0 {
+		cfg.CheckDuration = defaultCheckDuration
+	}
+	if cfg.LeaseDuration == 0 {
+		cfg.LeaseDuration = defaultLeaseDuration
+	}
+	if cfg.StuckJobAction == "" {
+		cfg.StuckJobAction = schedule.StuckJobActionRequeue
+	}
+	if cfg.MaxRecoveries == 0 {
+		cfg.MaxRecoveries = defaultMaxRecoveries
 	}
 	return &Service{
 		logger:          logger,
 		scheduleStorage: scheduleStorage,
-		checkDuration:   checkDuration,
+		leader:          leader,
+		checkDuration:   cfg.CheckDuration,
+		leaseDuration:   cfg.LeaseDuration,
+		stuckJobAction:  cfg.StuckJobAction,
+		maxRecoveries:   cfg.MaxRecoveries,
 		doneChan:        make(chan struct{}),
 	}
 }
 
-func (s *Service) Start() {
+func (s *Service) Start() error {
+	switch s.stuckJobAction {
+	case schedule.StuckJobActionRequeue, schedule.StuckJobActionFail, schedule.StuckJobActionAlert:
+	default:
+		return errors.Errorf("unsupported stuck job action = %q", s.stuckJobAction)
+	}
+	if s.leaseDuration < schedule.MinLeaseDuration {
+		return errors.Errorf("lease duration = %s is less than the min = %s", s.leaseDuration, schedule.MinLeaseDuration)
+	}
 	go s.doUntilStop()
+	return nil
 }
 
 func (s *Service) Stop() {
@@ -61,11 +104,13 @@ func (s *Service) doUntilStop() {
 }
 
 func (s *Service) do() {
+	if !s.leader.IsLeader() {
+		return
+	}
 	ctx := context.Background()
-	dateTime := time.Now().Add(maxRunningDuration)
-	jobs, err := s.scheduleStorage.GetRunningJobsForTooLong(ctx, dateTime)
+	jobs, err := s.scheduleStorage.GetStuckJobs(ctx, s.leaseDuration)
 	if err != nil {
-		s.logger.Error("failed to get running jobs for too long", zap.Error(err))
+		s.logger.Error("failed to get stuck jobs", zap.Error(err))
 		return
 	}
 
@@ -84,11 +129,41 @@ func (s *Service) do() {
 				wg.Done()
 				<-semaphore
 			}()
-			if err := s.scheduleStorage.RenewJob(ctx, jobs[i]); err != nil {
-				s.logger.Error("failed to renew job", zap.Int64("jobID", jobs[i].ID), zap.Error(err))
-			}
+			s.recoverJob(ctx, jobs[i])
 		}()
 	}
 	wg.Wait()
 	close(semaphore)
 }
+
+// recoverJob applies the stuck job action. A stuck execution isn't counted against max_attempts,
+// since the job may have been lost along with a healthy worker, but a job that keeps killing its workers
+// ends up in the dead state after maxRecoveries requeues instead of being requeued forever.
+func (s *Service) recoverJob(ctx context.Context, stuckJob schedule.StuckJob) {
+	job := stuckJob.Job
+	action := s.stuckJobAction
+	if action == schedule.StuckJobActionRequeue && stuckJob.Recoveries >= s.maxRecoveries {
+		action = schedule.StuckJobActionFail
+	}
+
+	fields := []zap.Field{
+		zap.Int64("jobID", job.ID),
+		zap.String("queueID", job.Queue.QueueID),
+		zap.Stringp("workerID", job.WorkerID),
+		zap.Timep("lastHeartBeat", job.LastHeartBeat),
+		zap.String("action", action),
+		zap.Int("recoveries", stuckJob.Recoveries),
+	}
+	recovered, err := s.scheduleStorage.RecoverStuckJob(ctx, job, action, stuckJobReason)
+	if errors.Is(err, schedule.ErrLeaseLost) {
+		s.logger.Info("job is not stuck anymore", fields...)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to recover stuck job", append(fields, zap.Error(err))...)
+		return
+	}
+	if recovered {
+		s.logger.Warn("stuck job recovered", fields...)
+	}
+}
@@ -0,0 +1,190 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCheckDuration = 5 * time.Second
+	CheckerLockKey       = 1
+)
+
+type Config struct {
+	// ID identifies the replica, it's the application name of the lock connection
+	// and the leader id reported by other replicas.
+	ID      string
+	LockKey int32
+	// CheckDuration is how often a standby tries to take the lock and the leader checks it still holds it,
+	// so a standby takes over within about two check durations after the leader connection is gone.
+	CheckDuration time.Duration
+}
+
+// Elector holds a session level advisory lock on a dedicated connection, the replica holding
+// the lock is the leader. The lock is released by Postgres as soon as the leader connection is closed.
+type Elector struct {
+	logger        *zap.Logger
+	connConfig    *pgx.ConnConfig
+	id            string
+	lockKey       int32
+	checkDuration time.Duration
+
+	isLeader int32
+
+	// conn and leaderID are used by the election goroutine only.
+	conn     *pgx.Conn
+	leaderID string
+
+	doneChan    chan struct{}
+	stoppedChan chan struct{}
+}
+
+func NewElector(logger *zap.Logger, connConfig *pgx.ConnConfig, cfg Config) *Elector {
+	if cfg.CheckDuration == 0 {
+		cfg.CheckDuration = defaultCheckDuration
+	}
+	connConfig = connConfig.Copy()
+	connConfig.RuntimeParams["application_name"] = cfg.ID
+	return &Elector{
+		logger:        logger.With(zap.String("service", "leader"), zap.String("id", cfg.ID)),
+		connConfig:    connConfig,
+		id:            cfg.ID,
+		lockKey:       cfg.LockKey,
+		checkDuration: cfg.CheckDuration,
+		doneChan:      make(chan struct{}),
+		stoppedChan:   make(chan struct{}),
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	return atomic.LoadInt32(&e.isLeader) == 1
+}
+
+func (e *Elector) Start() {
+	go e.doUntilStop()
+}
+
+// Stop releases the leadership, so a standby can take over without waiting for the connection to time out.
+func (e *Elector) Stop() {
+	close(e.doneChan)
+	<-e.stoppedChan
+}
+
+func (e *Elector) doUntilStop() {
+	defer close(e.stoppedChan)
+
+	ticker := time.NewTicker(e.checkDuration)
+	defer ticker.Stop()
+
+	e.do()
+	for {
+		select {
+		case <-e.doneChan:
+			e.release()
+			return
+		case <-ticker.C:
+			e.do()
+		}
+	}
+}
+
+func (e *Elector) do() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.checkDuration)
+	defer cancel()
+
+	if e.conn == nil {
+		conn, err := pgx.ConnectConfig(ctx, e.connConfig)
+		if err != nil {
+			e.logger.Error("failed to connect", zap.Error(err))
+			return
+		}
+		e.conn = conn
+	}
+
+	if !e.IsLeader() {
+		acquired, err := tryLock(ctx, e.conn, e.lockKey)
+		if err != nil {
+			e.logger.Error("failed to try lock", zap.Error(err))
+			e.closeConn()
+			return
+		}
+		if acquired {
+			e.setLeader(true)
+		}
+	}
+
+	// the leader makes sure its session still holds the lock, standbys just watch the current leader
+	h, err := getHolder(ctx, e.conn, e.lockKey)
+	if err != nil {
+		e.logger.Error("failed to get leader", zap.Error(err))
+		e.closeConn()
+		return
+	}
+	if e.IsLeader() && (h == nil || h.PID != e.conn.PgConn().PID()) {
+		e.setLeader(false)
+	}
+	leaderID := ""
+	if h != nil {
+		leaderID = h.ApplicationName
+	}
+	e.observeLeader(leaderID)
+}
+
+// closeConn drops the connection, and with it the lock. A replica which can't check the lock
+// steps down right away, since a standby may take over once the connection is gone.
+func (e *Elector) closeConn() {
+	e.setLeader(false)
+	ctx, cancel := context.WithTimeout(context.Background(), e.checkDuration)
+	defer cancel()
+	_ = e.conn.Close(ctx)
+	e.conn = nil
+}
+
+func (e *Elector) release() {
+	if e.conn == nil {
+		return
+	}
+	if e.IsLeader() {
+		ctx, cancel := context.WithTimeout(context.Background(), e.checkDuration)
+		defer cancel()
+		if err := unlock(ctx, e.conn, e.lockKey); err != nil {
+			e.logger.Error("failed to unlock", zap.Error(err))
+		}
+	}
+	e.closeConn()
+}
+
+func (e *Elector) setLeader(isLeader bool) {
+	var value int32
+	if isLeader {
+		value = 1
+	}
+	if atomic.SwapInt32(&e.isLeader, value) == value {
+		return
+	}
+	if isLeader {
+		e.logger.Info("leadership acquired")
+		isLeaderGauge.Set(1)
+		leadershipTransitionsTotal.WithLabelValues("acquired").Inc()
+		return
+	}
+	e.logger.Warn("leadership lost")
+	isLeaderGauge.Set(0)
+	leadershipTransitionsTotal.WithLabelValues("lost").Inc()
+}
+
+func (e *Elector) observeLeader(leaderID string) {
+	if leaderID == e.leaderID {
+		return
+	}
+	e.logger.Info("leader changed", zap.String("previousLeaderID", e.leaderID), zap.String("leaderID", leaderID))
+	leaderInfo.Reset()
+	if leaderID != "" {
+		leaderInfo.WithLabelValues(leaderID).Set(1)
+	}
+	e.leaderID = leaderID
+}
@@ -0,0 +1,71 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+const testLockKey = 4242
+
+func newTestElector(t *testing.T, id string) *Elector {
+	t.Helper()
+	url := os.Getenv("QS_TEST_DB_URL")
+	if url == "" {
+		t.Skip("QS_TEST_DB_URL is not set")
+	}
+	connConfig, err := pgx.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parse db url: %v", err)
+	}
+	return NewElector(zap.NewNop(), connConfig, Config{ID: id, LockKey: testLockKey, CheckDuration: 50 * time.Millisecond})
+}
+
+func waitLeader(t *testing.T, e *Elector, isLeader bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if e.IsLeader() == isLeader {
+			return
+		}
+	}
+	t.Fatalf("%s is leader = %v, want %v", e.id, e.IsLeader(), isLeader)
+}
+
+func TestElectorAcquireLossAndReacquire(t *testing.T) {
+	e := newTestElector(t, "elector")
+	ctx := context.Background()
+	conn, err := pgx.ConnectConfig(ctx, e.connConfig)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	e.Start()
+	defer e.Stop()
+	waitLeader(t, e, true)
+	if acquired, err := tryLock(ctx, conn, testLockKey); err != nil || acquired {
+		t.Fatalf("lock of the leader acquired = %v, err = %v", acquired, err)
+	}
+
+	// the lock connection of the leader is gone and another session takes the lock over
+	if _, err = conn.Exec(ctx, `
+		SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+		WHERE application_name = $1 AND pid <> pg_backend_pid()`, e.id); err != nil {
+		t.Fatalf("terminate leader connection: %v", err)
+	}
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1, $2)", int32(lockNamespace), int32(testLockKey)); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	waitLeader(t, e, false)
+	time.Sleep(200 * time.Millisecond)
+	waitLeader(t, e, false)
+
+	if err = unlock(ctx, conn, testLockKey); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	waitLeader(t, e, true)
+}
@@ -0,0 +1,57 @@
+package leader
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// lockNamespace is the first key of the advisory locks taken by the queue scheduler,
+// the second one tells the locks apart.
+const lockNamespace = 7173
+
+const tryLockQuery = `SELECT pg_try_advisory_lock($1, $2)`
+
+func tryLock(ctx context.Context, conn *pgx.Conn, key int32) (bool, error) {
+	var acquired bool
+	if err := conn.QueryRow(ctx, tryLockQuery, int32(lockNamespace), key).Scan(&acquired); err != nil {
+		return false, errors.Wrap(err, "query row")
+	}
+	return acquired, nil
+}
+
+const unlockQuery = `SELECT pg_advisory_unlock($1, $2)`
+
+func unlock(ctx context.Context, conn *pgx.Conn, key int32) error {
+	_, err := conn.Exec(ctx, unlockQuery, int32(lockNamespace), key)
+	return errors.Wrap(err, "exec query")
+}
+
+type holder struct {
+	PID             uint32 `db:"pid"`
+	ApplicationName string `db:"application_name"`
+}
+
+// getHolderQuery finds the session holding the advisory lock, locks taken with two keys
+// are reported with the keys in classid and objid and with objsubid 2.
+const getHolderQuery = `
+	SELECT a.pid, a.application_name
+	FROM pg_locks AS l
+	INNER JOIN pg_stat_activity AS a
+		ON l.pid = a.pid
+	WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 2
+		AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND l.classid::BIGINT = $1 AND l.objid::BIGINT = $2`
+
+func getHolder(ctx context.Context, conn *pgx.Conn, key int32) (*holder, error) {
+	var holders []holder
+	if err := pgxscan.Select(ctx, conn, &holders, getHolderQuery, int64(lockNamespace), int64(key)); err != nil {
+		return nil, errors.Wrap(err, "pgxscan select")
+	}
+	if len(holders) == 0 {
+		return nil, nil
+	}
+	return &holders[0], nil
+}
@@ -0,0 +1,27 @@
+package leader
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	isLeaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "qs",
+		Subsystem: "checker",
+		Name:      "is_leader",
+		Help:      "Whether this checker is the leader.",
+	})
+	leaderInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "qs",
+		Subsystem: "checker",
+		Name:      "leader_info",
+		Help:      "The current leader as seen by this checker, the value is always 1.",
+	}, []string{"leader_id"})
+	leadershipTransitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "qs",
+		Subsystem: "checker",
+		Name:      "leadership_transitions_total",
+		Help:      "Amount of times this checker has acquired or lost the leadership.",
+	}, []string{"transition"})
+)
@@ -0,0 +1,103 @@
+package reconcile
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"go.uber.org/zap"
+)
+
+const defaultCheckDuration = 1 * time.Minute
+
+type scheduleStorage interface {
+	GetQueuesWithRunningMismatch(ctx context.Context) ([]schedule.QueueRunningRepair, error)
+	RepairQueueRunning(ctx context.Context, queueInternalID int64) (*schedule.QueueRunningRepair, error)
+}
+
+type leader interface {
+	IsLeader() bool
+}
+
+// Service repairs running counters of queues which don't match their running jobs, e.g. after a job
+// has been deleted by hand. A counter above the running jobs blocks the queue for good,
+// and a counter below them lets the queue exceed its max concurrency.
+type Service struct {
+	logger          *zap.Logger
+	scheduleStorage scheduleStorage
+	leader          leader
+	checkDuration   time.Duration
+	doneChan        chan struct{}
+}
+
+func NewService(logger *zap.Logger, scheduleStorage scheduleStorage, leader leader,
+	checkDuration time.Duration) *Service {
+	if checkDuration == 0 {
+		checkDuration = defaultCheckDuration
+	}
+	return &Service{
+		logger:          logger.With(zap.String("service", "reconcile")),
+		scheduleStorage: scheduleStorage,
+		leader:          leader,
+		checkDuration:   checkDuration,
+		doneChan:        make(chan struct{}),
+	}
+}
+
+func (s *Service) Start() {
+	go s.doUntilStop()
+}
+
+func (s *Service) Stop() {
+	close(s.doneChan)
+}
+
+func (s *Service) doUntilStop() {
+	ticker := time.NewTicker(s.checkDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			s.do()
+		}
+	}
+}
+
+func (s *Service) do() {
+	if !s.leader.IsLeader() {
+		return
+	}
+	ctx := context.Background()
+	candidates, err := s.scheduleStorage.GetQueuesWithRunningMismatch(ctx)
+	if err != nil {
+		s.logger.Error("failed to get queues with running mismatch", zap.Error(err))
+		return
+	}
+
+	var repaired, failed int
+	for i := range candidates {
+		repair, err := s.scheduleStorage.RepairQueueRunning(ctx, candidates[i].ID)
+		if err != nil {
+			failed++
+			s.logger.Error("failed to repair queue running counter",
+				zap.String("queueID", candidates[i].QueueID), zap.Error(err))
+			continue
+		}
+		// the mismatch was caused by a claim or a transition in progress
+		if repair == nil {
+			continue
+		}
+		repaired++
+		s.logger.Warn("queue running counter repaired",
+			zap.String("queueID", repair.QueueID),
+			zap.Int("running", repair.Running),
+			zap.Int("actualRunning", repair.ActualRunning))
+	}
+	if repaired > 0 || failed > 0 {
+		s.logger.Info("queues reconciled",
+			zap.Int("candidates", len(candidates)), zap.Int("repaired", repaired), zap.Int("failed", failed))
+	}
+}
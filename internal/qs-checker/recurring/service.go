@@ -0,0 +1,158 @@
+package recurring
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCheckDuration = 10 * time.Second
+	maxCatchUpRuns       = 1000
+	maxUpcomingRuns      = 1000
+)
+
+type scheduleStorage interface {
+	GetDueRecurringJobs(ctx context.Context, dateTime time.Time) ([]schedule.RecurringJob, error)
+	MaterializeRecurringJob(ctx context.Context,
+		job schedule.RecurringJob, runs []time.Time, nextRunAt time.Time) (int, error)
+}
+
+type leader interface {
+	IsLeader() bool
+}
+
+type Service struct {
+	logger          *zap.Logger
+	scheduleStorage scheduleStorage
+	leader          leader
+	checkDuration   time.Duration
+	lookahead       time.Duration
+	doneChan        chan struct{}
+}
+
+func NewService(logger *zap.Logger, scheduleStorage scheduleStorage, leader leader,
+	checkDuration, lookahead time.Duration) *Service {
+	if checkDuration == 0 {
+		checkDuration = defaultCheckDuration
+	}
+	// runs must be materialized before the next check, otherwise every run would be treated as missed
+	if lookahead < 2*checkDuration {
+		lookahead = 2 * checkDuration
+	}
+	return &Service{
+		logger:          logger.With(zap.String("service", "recurring")),
+		scheduleStorage: scheduleStorage,
+		leader:          leader,
+		checkDuration:   checkDuration,
+		lookahead:       lookahead,
+		doneChan:        make(chan struct{}),
+	}
+}
+
+func (s *Service) Start() {
+	go s.doUntilStop()
+}
+
+func (s *Service) Stop() {
+	close(s.doneChan)
+}
+
+func (s *Service) doUntilStop() {
+	ticker := time.NewTicker(s.checkDuration)
+	defer ticker.Stop()
+
+	s.do()
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			s.do()
+		}
+	}
+}
+
+func (s *Service) do() {
+	if !s.leader.IsLeader() {
+		return
+	}
+	ctx := context.Background()
+	now := time.Now()
+	jobs, err := s.scheduleStorage.GetDueRecurringJobs(ctx, now.Add(s.lookahead))
+	if err != nil {
+		s.logger.Error("failed to get due recurring jobs", zap.Error(err))
+		return
+	}
+
+	for i := range jobs {
+		logger := s.logger.With(zap.Int64("scheduleID", jobs[i].ID), zap.String("name", jobs[i].Name))
+		runs, nextRunAt, err := s.plan(jobs[i], now)
+		if err != nil {
+			logger.Error("failed to plan recurring job runs", zap.Error(err))
+			continue
+		}
+		inserted, err := s.scheduleStorage.MaterializeRecurringJob(ctx, jobs[i], runs, nextRunAt)
+		if err != nil {
+			logger.Error("failed to materialize recurring job", zap.Error(err))
+			continue
+		}
+		if inserted > 0 {
+			logger.Info("recurring job materialized", zap.Int("inserted", inserted), zap.Time("nextRunAt", nextRunAt))
+		}
+	}
+}
+
+// plan returns the runs to insert and the next run time to store. Runs before now were missed
+// while nobody materialized them and are handled by the catch-up policy of the job.
+// Upcoming runs within the lookahead are always inserted.
+func (s *Service) plan(job schedule.RecurringJob, now time.Time) ([]time.Time, time.Time, error) {
+	cronSchedule, err := schedule.ParseCronSchedule(job.CronExpression, job.Timezone)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "parse cron schedule")
+	}
+
+	var runs []time.Time
+	next := cronSchedule.Next(now)
+	if job.NextRunAt != nil && !job.NextRunAt.After(now) {
+		runs = s.catchUp(job, cronSchedule, now)
+	} else if job.NextRunAt != nil {
+		next = *job.NextRunAt
+	}
+
+	horizon := now.Add(s.lookahead)
+	for upcoming := 0; !next.IsZero() && !next.After(horizon) && upcoming < maxUpcomingRuns; upcoming++ {
+		runs = append(runs, next)
+		next = cronSchedule.Next(next)
+	}
+	if next.IsZero() {
+		return nil, time.Time{}, errors.New("cron expression has no upcoming runs")
+	}
+	return runs, next, nil
+}
+
+func (s *Service) catchUp(job schedule.RecurringJob, cronSchedule schedule.CronSchedule, now time.Time) []time.Time {
+	switch job.CatchUpPolicy {
+	case schedule.CatchUpPolicySkip:
+		s.logger.Info("skip missed recurring job runs", zap.Int64("scheduleID", job.ID),
+			zap.Time("missedSince", *job.NextRunAt))
+		return nil
+	case schedule.CatchUpPolicyAll:
+		var runs []time.Time
+		for t := *job.NextRunAt; !t.IsZero() && !t.After(now); t = cronSchedule.Next(t) {
+			if len(runs) == maxCatchUpRuns {
+				s.logger.Warn("too many missed recurring job runs, the rest are skipped",
+					zap.Int64("scheduleID", job.ID), zap.Int("maxCatchUpRuns", maxCatchUpRuns))
+				break
+			}
+			runs = append(runs, t)
+		}
+		return runs
+	default:
+		// the first missed run time keeps the catch-up run idempotent between concurrent checkers
+		return []time.Time{*job.NextRunAt}
+	}
+}
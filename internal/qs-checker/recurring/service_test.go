@@ -0,0 +1,92 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"go.uber.org/zap"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	at := func(hour int) time.Time {
+		return time.Date(2026, 1, 1, hour, 0, 0, 0, time.UTC)
+	}
+	timePtr := func(t time.Time) *time.Time {
+		return &t
+	}
+
+	for _, tc := range []struct {
+		name          string
+		policy        schedule.CatchUpPolicy
+		nextRunAt     *time.Time
+		wantRuns      []time.Time
+		wantNextRunAt time.Time
+	}{
+		{name: "new job", nextRunAt: nil, wantRuns: []time.Time{at(11), at(12)}, wantNextRunAt: at(13)},
+		{name: "upcoming run", nextRunAt: timePtr(at(11)), wantRuns: []time.Time{at(11), at(12)}, wantNextRunAt: at(13)},
+		{name: "run beyond lookahead", nextRunAt: timePtr(at(15)), wantRuns: nil, wantNextRunAt: at(15)},
+		{
+			name: "skip missed", policy: schedule.CatchUpPolicySkip, nextRunAt: timePtr(at(7)),
+			wantRuns: []time.Time{at(11), at(12)}, wantNextRunAt: at(13),
+		},
+		{
+			name: "once missed", policy: schedule.CatchUpPolicyOnce, nextRunAt: timePtr(at(7)),
+			wantRuns: []time.Time{at(7), at(11), at(12)}, wantNextRunAt: at(13),
+		},
+		{
+			name: "all missed", policy: schedule.CatchUpPolicyAll, nextRunAt: timePtr(at(7)),
+			wantRuns: []time.Time{at(7), at(8), at(9), at(10), at(11), at(12)}, wantNextRunAt: at(13),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewService(zap.NewNop(), nil, nil, time.Minute, 2*time.Hour)
+			runs, nextRunAt, err := s.plan(schedule.RecurringJob{
+				CronExpression: "0 * * * *",
+				CatchUpPolicy:  tc.policy,
+				NextRunAt:      tc.nextRunAt,
+			}, now)
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if len(runs) != len(tc.wantRuns) {
+				t.Fatalf("runs = %v, want %v", runs, tc.wantRuns)
+			}
+			for i := range runs {
+				if !runs[i].Equal(tc.wantRuns[i]) {
+					t.Fatalf("runs = %v, want %v", runs, tc.wantRuns)
+				}
+			}
+			if !nextRunAt.Equal(tc.wantNextRunAt) {
+				t.Errorf("next run at = %v, want %v", nextRunAt, tc.wantNextRunAt)
+			}
+		})
+	}
+}
+
+func TestPlanCapsMissedRuns(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	missedSince := now.Add(-10 * maxCatchUpRuns * time.Hour)
+	s := NewService(zap.NewNop(), nil, nil, time.Minute, 2*time.Hour)
+
+	runs, _, err := s.plan(schedule.RecurringJob{
+		CronExpression: "0 * * * *",
+		CatchUpPolicy:  schedule.CatchUpPolicyAll,
+		NextRunAt:      &missedSince,
+	}, now)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	// the capped catch-up runs are followed by the two upcoming ones
+	if len(runs) != maxCatchUpRuns+2 {
+		t.Errorf("planned %d runs, want %d", len(runs), maxCatchUpRuns+2)
+	}
+}
+
+func TestPlanRejectsInvalidCronExpression(t *testing.T) {
+	s := NewService(zap.NewNop(), nil, nil, time.Minute, time.Hour)
+	if _, _, err := s.plan(schedule.RecurringJob{CronExpression: "every hour"}, time.Now()); err == nil {
+		t.Error("plan of an invalid cron expression succeeded")
+	}
+}
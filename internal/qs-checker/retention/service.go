@@ -0,0 +1,179 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCheckDuration    = 10 * time.Minute
+	defaultBatchSize        = 1000
+	defaultBatchPause       = 100 * time.Millisecond
+	defaultMaxBatchesPerRun = 100
+)
+
+type scheduleStorage interface {
+	SweepExpiredJobs(ctx context.Context,
+		mode schedule.RetentionMode, defaultRetention, deadRetention *time.Duration, limit int) (int, error)
+	BackfillFinishedAt(ctx context.Context, limit int) (int, error)
+}
+
+type leader interface {
+	IsLeader() bool
+}
+
+type Config struct {
+	CheckDuration time.Duration
+	Mode          schedule.RetentionMode
+	// DefaultRetention applies to queues without a retention of their own, zero keeps their jobs forever.
+	DefaultRetention time.Duration
+	// DeadRetention applies to dead jobs of all queues instead, zero keeps them forever.
+	DeadRetention    time.Duration
+	BatchSize        int
+	BatchPause       time.Duration
+	MaxBatchesPerRun int
+}
+
+// Jobs finished before their finish time was recorded get it estimated first.
+type Service struct {
+	logger           *zap.Logger
+	scheduleStorage  scheduleStorage
+	leader           leader
+	checkDuration    time.Duration
+	mode             schedule.RetentionMode
+	defaultRetention *time.Duration
+	deadRetention    *time.Duration
+	batchSize        int
+	batchPause       time.Duration
+	maxBatchesPerRun int
+	doneChan         chan struct{}
+}
+
+func NewService(logger *zap.Logger, scheduleStorage scheduleStorage, leader leader, cfg Config) *Service {
+	if cfg.CheckDuration == 0 {
+		cfg.CheckDuration = defaultCheckDuration
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = schedule.RetentionModeDelete
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.BatchPause == 0 {
+		cfg.BatchPause = defaultBatchPause
+	}
+	if cfg.MaxBatchesPerRun == 0 {
+		cfg.MaxBatchesPerRun = defaultMaxBatchesPerRun
+	}
+	var defaultRetention *time.Duration
+	if cfg.DefaultRetention > 0 {
+		defaultRetention = &cfg.DefaultRetention
+	}
+	var deadRetention *time.Duration
+	if cfg.DeadRetention > 0 {
+		deadRetention = &cfg.DeadRetention
+	}
+	return &Service{
+		logger:           logger.With(zap.String("service", "retention")),
+		scheduleStorage:  scheduleStorage,
+		leader:           leader,
+		checkDuration:    cfg.CheckDuration,
+		mode:             cfg.Mode,
+		defaultRetention: defaultRetention,
+		deadRetention:    deadRetention,
+		batchSize:        cfg.BatchSize,
+		batchPause:       cfg.BatchPause,
+		maxBatchesPerRun: cfg.MaxBatchesPerRun,
+		doneChan:         make(chan struct{}),
+	}
+}
+
+func (s *Service) Start() error {
+	switch s.mode {
+	case schedule.RetentionModeDelete, schedule.RetentionModeArchive:
+	default:
+		return errors.Errorf("unsupported retention mode = %q", s.mode)
+	}
+	go s.doUntilStop()
+	return nil
+}
+
+func (s *Service) Stop() {
+	close(s.doneChan)
+}
+
+func (s *Service) doUntilStop() {
+	ticker := time.NewTicker(s.checkDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			s.do()
+		}
+	}
+}
+
+func (s *Service) do() {
+	if !s.leader.IsLeader() {
+		return
+	}
+	ctx := context.Background()
+
+	startedAt := time.Now()
+	backfilled, batches := s.doBatches("failed to backfill finished at", func() (int, error) {
+		return s.scheduleStorage.BackfillFinishedAt(ctx, s.batchSize)
+	})
+	if backfilled > 0 {
+		s.logger.Info("finished at of jobs backfilled",
+			zap.Int("backfilled", backfilled),
+			zap.Int("batches", batches),
+			zap.Duration("duration", time.Since(startedAt)))
+	}
+
+	startedAt = time.Now()
+	swept, batches := s.doBatches("failed to sweep expired jobs", func() (int, error) {
+		return s.scheduleStorage.SweepExpiredJobs(ctx, s.mode, s.defaultRetention, s.deadRetention, s.batchSize)
+	})
+	if swept > 0 {
+		s.logger.Info("expired jobs swept",
+			zap.String("mode", s.mode),
+			zap.Int("swept", swept),
+			zap.Int("batches", batches),
+			zap.Duration("duration", time.Since(startedAt)))
+	}
+}
+
+// doBatches calls the batch until it handles less than a full batch, the batches per run are over,
+// or the service loses the leadership, and returns the total amount of handled jobs and batches.
+// A failed batch is logged with the message and stops the calls.
+func (s *Service) doBatches(errMsg string, batch func() (int, error)) (total, batches int) {
+	for batches < s.maxBatchesPerRun {
+		n, err := batch()
+		if err != nil {
+			s.logger.Error(errMsg, zap.Error(err))
+			return total, batches
+		}
+		batches++
+		total += n
+		if n < s.batchSize {
+			return total, batches
+		}
+
+		select {
+		case <-s.doneChan:
+			return total, batches
+		case <-time.After(s.batchPause):
+		}
+		if !s.leader.IsLeader() {
+			return total, batches
+		}
+	}
+	return total, batches
+}
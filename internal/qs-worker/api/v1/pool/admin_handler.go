@@ -0,0 +1,81 @@
+package pool
+
+import (
+	"github.com/SwirlGit/queue-scheduler/internal/qs-worker/schedule"
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+type adminPoolService interface {
+	Stats() schedule.PoolStats
+	Resize(workersAmount int) error
+	PauseClaiming()
+	ResumeClaiming()
+	ResumeAutoscaling() error
+}
+
+// AdminHandler lets operators control the worker pool at runtime. It changes what the worker does,
+// so it's meant to be served on the admin port, which isn't exposed together with metrics.
+type AdminHandler struct {
+	poolService adminPoolService
+}
+
+func NewAdminHandler(poolService adminPoolService) *AdminHandler {
+	return &AdminHandler{poolService: poolService}
+}
+
+func (h *AdminHandler) RegisterFastHTTPRouters(a fiber.Router) {
+	a.Get("/api/v1/pool", h.getPool)
+	a.Put("/api/v1/pool/size", h.resizePool)
+	a.Post("/api/v1/pool/pause", h.pauseClaiming)
+	a.Post("/api/v1/pool/resume", h.resumeClaiming)
+	a.Post("/api/v1/pool/autoscaling/resume", h.resumeAutoscaling)
+}
+
+func (h *AdminHandler) getPool(c *fiber.Ctx) error {
+	return c.JSON(newPoolResponse(h.poolService.Stats()))
+}
+
+type resizePoolArgs struct {
+	Workers *int `json:"workers"`
+}
+
+func (h *AdminHandler) resizePool(c *fiber.Ctx) error {
+	var args resizePoolArgs
+	if err := c.BodyParser(&args); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if args.Workers == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "missing workers field")
+	}
+	if *args.Workers < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid workers field: must not be negative")
+	}
+
+	if err := h.poolService.Resize(*args.Workers); err != nil {
+		return errors.Wrap(err, "resize pool")
+	}
+	return c.JSON(newPoolResponse(h.poolService.Stats()))
+}
+
+func (h *AdminHandler) pauseClaiming(c *fiber.Ctx) error {
+	h.poolService.PauseClaiming()
+	return c.JSON(newPoolResponse(h.poolService.Stats()))
+}
+
+func (h *AdminHandler) resumeClaiming(c *fiber.Ctx) error {
+	h.poolService.ResumeClaiming()
+	return c.JSON(newPoolResponse(h.poolService.Stats()))
+}
+
+func (h *AdminHandler) resumeAutoscaling(c *fiber.Ctx) error {
+	err := h.poolService.ResumeAutoscaling()
+	if errors.Is(err, schedule.ErrAutoscalingDisabled) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return errors.Wrap(err, "resume autoscaling")
+	}
+	return c.JSON(newPoolResponse(h.poolService.Stats()))
+}
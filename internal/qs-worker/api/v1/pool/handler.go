@@ -0,0 +1,65 @@
+package pool
+
+import (
+	"github.com/SwirlGit/queue-scheduler/internal/qs-worker/schedule"
+	"github.com/gofiber/fiber/v2"
+)
+
+type poolService interface {
+	Stats() schedule.PoolStats
+}
+
+type Handler struct {
+	poolService poolService
+}
+
+func NewHandler(poolService poolService) *Handler {
+	return &Handler{poolService: poolService}
+}
+
+func (h *Handler) RegisterFastHTTPRouters(a fiber.Router) {
+	a.Get("/api/v1/pool", h.getPool)
+}
+
+type inFlightJobResponse struct {
+	ID        int64  `json:"id"`
+	QueueID   string `json:"queue_id"`
+	Action    string `json:"action"`
+	Attempts  int    `json:"attempts"`
+	StartedAt int64  `json:"started_at"`
+}
+
+type poolResponse struct {
+	Workers        int                   `json:"workers"`
+	Busy           int                   `json:"busy"`
+	Idle           int                   `json:"idle"`
+	ClaimingPaused bool                  `json:"claiming_paused"`
+	Autoscaling    string                `json:"autoscaling"`
+	InFlight       []inFlightJobResponse `json:"in_flight"`
+}
+
+func (h *Handler) getPool(c *fiber.Ctx) error {
+	return c.JSON(newPoolResponse(h.poolService.Stats()))
+}
+
+func newPoolResponse(stats schedule.PoolStats) poolResponse {
+	resp := poolResponse{
+		Workers:        stats.Workers,
+		Busy:           stats.Busy,
+		Idle:           stats.Idle,
+		ClaimingPaused: stats.ClaimingPaused,
+		Autoscaling:    stats.Autoscaling,
+		InFlight:       make([]inFlightJobResponse, 0, len(stats.InFlight)),
+	}
+	for i := range stats.InFlight {
+		job := stats.InFlight[i].Job
+		resp.InFlight = append(resp.InFlight, inFlightJobResponse{
+			ID:        job.ID,
+			QueueID:   job.QueueID,
+			Action:    job.Action,
+			Attempts:  job.Attempts,
+			StartedAt: stats.InFlight[i].StartedAt.Unix(),
+		})
+	}
+	return resp
+}
@@ -0,0 +1,193 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAutoscaleCheckDuration    = 10 * time.Second
+	defaultScaleUpCooldown           = 30 * time.Second
+	defaultScaleDownCooldown         = 2 * time.Minute
+	defaultMinClaimSuccessRate       = 0.5
+	defaultAutoscaleMaxWorkersAmount = 100
+	// instanceActiveChecks is how many autoscaler checks an instance may miss before it's no longer
+	// counted among the instances sharing the backlog.
+	instanceActiveChecks = 3
+)
+
+type backlogStorage interface {
+	GetDueBacklog(ctx context.Context) (int, error)
+	TouchWorkerInstance(ctx context.Context, workerID string, activeWithin time.Duration) (int, error)
+	RemoveWorkerInstance(ctx context.Context, workerID string) error
+}
+
+type pool interface {
+	Stats() PoolStats
+	ClaimStats() (requested, taken int64)
+	autoResize(workersAmount int) error
+	setAutoscaling(enabled bool)
+}
+
+type AutoscalerConfig struct {
+	WorkerID          string
+	MinWorkers        int
+	MaxWorkers        int
+	CheckDuration     time.Duration
+	ScaleUpCooldown   time.Duration
+	ScaleDownCooldown time.Duration
+	// Below MinClaimSuccessRate due jobs are held back by queue limits, so more workers wouldn't help.
+	MinClaimSuccessRate float64
+}
+
+// Autoscaler sizes the worker pool after its running jobs and its share of the due backlog,
+// which is split evenly between the active worker instances.
+type Autoscaler struct {
+	logger         *zap.Logger
+	backlogStorage backlogStorage
+	pool           pool
+	cfg            AutoscalerConfig
+
+	lastScaleAt   time.Time
+	lastRequested int64
+	lastTaken     int64
+	doneChan      chan struct{}
+	stoppedChan   chan struct{}
+}
+
+func NewAutoscaler(logger *zap.Logger, backlogStorage backlogStorage, pool pool, cfg AutoscalerConfig) *Autoscaler {
+	if cfg.MaxWorkers == 0 {
+		cfg.MaxWorkers = defaultAutoscaleMaxWorkersAmount
+	}
+	if cfg.CheckDuration == 0 {
+		cfg.CheckDuration = defaultAutoscaleCheckDuration
+	}
+	if cfg.ScaleUpCooldown == 0 {
+		cfg.ScaleUpCooldown = defaultScaleUpCooldown
+	}
+	if cfg.ScaleDownCooldown == 0 {
+		cfg.ScaleDownCooldown = defaultScaleDownCooldown
+	}
+	if cfg.MinClaimSuccessRate == 0 {
+		cfg.MinClaimSuccessRate = defaultMinClaimSuccessRate
+	}
+	return &Autoscaler{
+		logger:         logger.With(zap.String("service", "autoscaler")),
+		backlogStorage: backlogStorage,
+		pool:           pool,
+		cfg:            cfg,
+		doneChan:       make(chan struct{}),
+		stoppedChan:    make(chan struct{}),
+	}
+}
+
+func (a *Autoscaler) Start() error {
+	if a.cfg.MinWorkers < 0 || a.cfg.MinWorkers > a.cfg.MaxWorkers {
+		return errors.Errorf("invalid workers range = [%v, %v]", a.cfg.MinWorkers, a.cfg.MaxWorkers)
+	}
+	a.lastRequested, a.lastTaken = a.pool.ClaimStats()
+	a.pool.setAutoscaling(true)
+	go a.doUntilStop()
+	return nil
+}
+
+// Stop waits for the running check, so the worker instance isn't touched again after its removal.
+func (a *Autoscaler) Stop() {
+	close(a.doneChan)
+	<-a.stoppedChan
+	a.pool.setAutoscaling(false)
+	if err := a.backlogStorage.RemoveWorkerInstance(context.Background(), a.cfg.WorkerID); err != nil {
+		a.logger.Error("failed to remove worker instance", zap.Error(err))
+	}
+}
+
+func (a *Autoscaler) doUntilStop() {
+	defer close(a.stoppedChan)
+
+	ticker := time.NewTicker(a.cfg.CheckDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.doneChan:
+			return
+		case <-ticker.C:
+			a.do()
+		}
+	}
+}
+
+func (a *Autoscaler) do() {
+	stats := a.pool.Stats()
+	claimSuccessRate := a.claimSuccessRate()
+	// a paused instance takes no share of the backlog, so it isn't counted among the active ones either
+	if stats.ClaimingPaused {
+		return
+	}
+	instances, err := a.backlogStorage.TouchWorkerInstance(context.Background(), a.cfg.WorkerID,
+		instanceActiveChecks*a.cfg.CheckDuration)
+	if err != nil {
+		a.logger.Error("failed to touch worker instance", zap.Error(err))
+		return
+	}
+	if stats.Autoscaling != AutoscalingStateActive {
+		return
+	}
+	backlog, err := a.backlogStorage.GetDueBacklog(context.Background())
+	if err != nil {
+		a.logger.Error("failed to get due backlog", zap.Error(err))
+		return
+	}
+	share := (backlog + instances - 1) / instances
+
+	target := a.target(stats, share, claimSuccessRate)
+	if target == stats.Workers {
+		return
+	}
+	cooldown := a.cfg.ScaleDownCooldown
+	if target > stats.Workers {
+		cooldown = a.cfg.ScaleUpCooldown
+	}
+	if time.Since(a.lastScaleAt) < cooldown {
+		return
+	}
+
+	logger := a.logger.With(zap.Int("workers", stats.Workers), zap.Int("target", target),
+		zap.Int("busy", stats.Busy), zap.Int("backlog", backlog), zap.Int("instances", instances),
+		zap.Float64("claimSuccessRate", claimSuccessRate))
+	if err = a.pool.autoResize(target); err != nil {
+		logger.Error("failed to resize worker pool", zap.Error(err))
+		return
+	}
+	a.lastScaleAt = time.Now()
+	logger.Info("worker pool is resized")
+}
+
+// The pool doesn't grow while claims mostly come back empty, since the backlog is not claimable then.
+func (a *Autoscaler) target(stats PoolStats, backlogShare int, claimSuccessRate float64) int {
+	target := stats.Busy + backlogShare
+	if target > stats.Workers && claimSuccessRate < a.cfg.MinClaimSuccessRate {
+		target = stats.Workers
+	}
+	if target < a.cfg.MinWorkers {
+		target = a.cfg.MinWorkers
+	}
+	if target > a.cfg.MaxWorkers {
+		target = a.cfg.MaxWorkers
+	}
+	return target
+}
+
+// Without claims the rate is 1, as there has been nothing to fail.
+func (a *Autoscaler) claimSuccessRate() float64 {
+	requested, taken := a.pool.ClaimStats()
+	requestedDiff, takenDiff := requested-a.lastRequested, taken-a.lastTaken
+	a.lastRequested, a.lastTaken = requested, taken
+	if requestedDiff == 0 {
+		return 1
+	}
+	return float64(takenDiff) / float64(requestedDiff)
+}
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fakeBacklogStorage struct {
+	backlog             int
+	instances           int
+	touched             int
+	touchFn             func()
+	removed             bool
+	touchedAfterRemoval bool
+}
+
+func (f *fakeBacklogStorage) GetDueBacklog(context.Context) (int, error) {
+	return f.backlog, nil
+}
+
+func (f *fakeBacklogStorage) TouchWorkerInstance(context.Context, string, time.Duration) (int, error) {
+	if f.touchFn != nil {
+		f.touchFn()
+	}
+	if f.removed {
+		f.touchedAfterRemoval = true
+	}
+	f.touched++
+	return f.instances, nil
+}
+
+func (f *fakeBacklogStorage) RemoveWorkerInstance(context.Context, string) error {
+	f.removed = true
+	return nil
+}
+
+type fakePool struct {
+	stats   PoolStats
+	resizes []int
+}
+
+func (f *fakePool) Stats() PoolStats {
+	return f.stats
+}
+
+func (f *fakePool) ClaimStats() (requested, taken int64) {
+	return 0, 0
+}
+
+func (f *fakePool) autoResize(workersAmount int) error {
+	f.resizes = append(f.resizes, workersAmount)
+	return nil
+}
+
+func (f *fakePool) setAutoscaling(bool) {}
+
+func TestAutoscalerScalesToShareOfBacklog(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		stats       PoolStats
+		wantTouched bool
+		wantResizes []int
+	}{
+		{
+			name:        "active",
+			stats:       PoolStats{Workers: 1, Busy: 1, Autoscaling: AutoscalingStateActive},
+			wantTouched: true,
+			// one busy worker and a third of 29 due jobs rounded up
+			wantResizes: []int{11},
+		},
+		{
+			name:        "claiming paused",
+			stats:       PoolStats{Workers: 1, Busy: 1, ClaimingPaused: true, Autoscaling: AutoscalingStateActive},
+			wantTouched: false,
+		},
+		{
+			name:        "suspended by manual resize",
+			stats:       PoolStats{Workers: 1, Busy: 1, Autoscaling: AutoscalingStateSuspended},
+			wantTouched: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &fakeBacklogStorage{backlog: 29, instances: 3}
+			p := &fakePool{stats: tc.stats}
+			a := NewAutoscaler(zap.NewNop(), storage, p, AutoscalerConfig{WorkerID: "worker", MaxWorkers: 100})
+
+			a.do()
+			if touched := storage.touched > 0; touched != tc.wantTouched {
+				t.Errorf("worker instance touched = %v, want %v", touched, tc.wantTouched)
+			}
+			if len(p.resizes) != len(tc.wantResizes) || (len(p.resizes) > 0 && p.resizes[0] != tc.wantResizes[0]) {
+				t.Errorf("resizes = %v, want %v", p.resizes, tc.wantResizes)
+			}
+		})
+	}
+}
+
+func TestAutoscalerStopWaitsForRunningCheck(t *testing.T) {
+	touchStarted, releaseTouch := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	storage := &fakeBacklogStorage{instances: 1, touchFn: func() {
+		once.Do(func() { close(touchStarted) })
+		<-releaseTouch
+	}}
+	p := &fakePool{stats: PoolStats{Autoscaling: AutoscalingStateActive}}
+	a := NewAutoscaler(zap.NewNop(), storage, p, AutoscalerConfig{WorkerID: "worker", CheckDuration: time.Millisecond})
+	if err := a.Start(); err != nil {
+		t.Fatalf("start autoscaler: %v", err)
+	}
+	<-touchStarted
+
+	stopped := make(chan struct{})
+	go func() {
+		a.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("stop returned while a check is running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(releaseTouch)
+	<-stopped
+	if !storage.removed || storage.touchedAfterRemoval {
+		t.Errorf("removed = %v, touched after removal = %v", storage.removed, storage.touchedAfterRemoval)
+	}
+}
+
+func TestResizeSuspendsAutoscaling(t *testing.T) {
+	s := NewService(zap.NewNop(), &fakeStorage{}, NewRegistry(), Config{})
+	// the feeder isn't needed to resize the pool
+	s.started = true
+	defer s.Stop()
+
+	if err := s.ResumeAutoscaling(); err != ErrAutoscalingDisabled {
+		t.Errorf("resume of disabled autoscaling: err = %v, want %v", err, ErrAutoscalingDisabled)
+	}
+	s.setAutoscaling(true)
+	if err := s.Resize(2); err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	if state := s.Stats().Autoscaling; state != AutoscalingStateSuspended {
+		t.Errorf("autoscaling after a manual resize = %s, want %s", state, AutoscalingStateSuspended)
+	}
+	if err := s.autoResize(3); err == nil {
+		t.Error("autoscaler resized the pool while autoscaling is suspended")
+	}
+	if err := s.ResumeAutoscaling(); err != nil {
+		t.Fatalf("resume autoscaling: %v", err)
+	}
+	if err := s.autoResize(3); err != nil {
+		t.Errorf("autoscaler failed to resize the pool after resume: %v", err)
+	}
+	if workers := s.Stats().Workers; workers != 3 {
+		t.Errorf("workers = %d, want 3", workers)
+	}
+}
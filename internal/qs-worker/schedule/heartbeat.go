@@ -0,0 +1,58 @@
+package schedule
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const defaultHeartBeatDuration = 30 * time.Second
+
+type heartBeat struct {
+	doneChan    chan struct{}
+	stoppedChan chan struct{}
+	leaseLost   int32
+}
+
+func (s *Service) startHeartBeat(ctx context.Context, job schedule.Job, cancel context.CancelFunc) *heartBeat {
+	h := &heartBeat{
+		doneChan:    make(chan struct{}),
+		stoppedChan: make(chan struct{}),
+	}
+	go func() {
+		defer close(h.stoppedChan)
+
+		ticker := time.NewTicker(s.heartBeatDuration)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.doneChan:
+				return
+			case <-ticker.C:
+			}
+
+			err := s.scheduleStorage.HeartBeatJob(ctx, job)
+			if errors.Is(err, schedule.ErrLeaseLost) {
+				s.logger.Warn("job lease is lost, cancel job", zap.Int64("jobID", job.ID))
+				atomic.StoreInt32(&h.leaseLost, 1)
+				cancel()
+				return
+			}
+			if err != nil {
+				s.logger.Error("failed to heart beat job", zap.Int64("jobID", job.ID), zap.Error(err))
+			}
+		}
+	}()
+	return h
+}
+
+func (h *heartBeat) stop() bool {
+	close(h.doneChan)
+	<-h.stoppedChan
+	return atomic.LoadInt32(&h.leaseLost) == 1
+}
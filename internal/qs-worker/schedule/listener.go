@@ -0,0 +1,45 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultFallbackPollDuration = 5 * time.Second
+
+func (s *Service) listenUntilStop(ctx context.Context) {
+	defer s.wakeup.stop()
+
+	s.refreshNextJobDateTime(ctx)
+	for {
+		err := s.scheduleStorage.ListenJobs(ctx, s.wakeup.at)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Error("jobs listener is disconnected, fall back to polling", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.fallbackPollDuration):
+		}
+		// notifications sent while disconnected are lost, so check for jobs on every reconnect
+		s.wakeup.broadcast()
+		s.refreshNextJobDateTime(ctx)
+	}
+}
+
+func (s *Service) refreshNextJobDateTime(ctx context.Context) {
+	dateTime, err := s.scheduleStorage.GetNextJobDateTime(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("failed to get next job date time", zap.Error(err))
+		}
+		return
+	}
+	if dateTime != nil {
+		s.wakeup.at(*dateTime)
+	}
+}
@@ -0,0 +1,34 @@
+package schedule
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobsTakenTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "qs",
+		Subsystem: "worker",
+		Name:      "jobs_taken_total",
+		Help:      "Amount of jobs taken into work by priority.",
+	}, []string{"priority"})
+	jobWaitSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "qs",
+		Subsystem: "worker",
+		Name:      "job_wait_seconds",
+		Help:      "Time between the due time of a job and taking it into work by priority.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"priority"})
+)
+
+func observeTakenJobs(jobs []schedule.Job, now time.Time) {
+	for i := range jobs {
+		priority := strconv.Itoa(jobs[i].Priority)
+		jobsTakenTotal.WithLabelValues(priority).Inc()
+		jobWaitSeconds.WithLabelValues(priority).Observe(now.Sub(jobs[i].DateTime).Seconds())
+	}
+}
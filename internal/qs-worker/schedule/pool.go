@@ -0,0 +1,293 @@
+package schedule
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type worker struct {
+	stopChan chan struct{}
+}
+
+type InFlightJob struct {
+	Job       schedule.Job
+	StartedAt time.Time
+}
+
+type AutoscalingState = string
+
+const (
+	AutoscalingStateDisabled = AutoscalingState("disabled")
+	AutoscalingStateActive   = AutoscalingState("active")
+	// AutoscalingStateSuspended is entered on a manual resize, so the autoscaler doesn't undo it,
+	// until autoscaling is resumed.
+	AutoscalingStateSuspended = AutoscalingState("suspended")
+)
+
+var ErrAutoscalingDisabled = errors.New("autoscaling is disabled")
+
+// PoolStats describes the worker pool. Workers doesn't count removed workers which are still
+// finishing their jobs, while Busy and InFlight take jobs of all workers into account.
+type PoolStats struct {
+	Workers        int
+	Busy           int
+	Idle           int
+	ClaimingPaused bool
+	Autoscaling    AutoscalingState
+	InFlight       []InFlightJob
+}
+
+func (s *Service) Stats() PoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := PoolStats{
+		Workers:        len(s.workers),
+		Busy:           len(s.inFlight),
+		Idle:           int(atomic.LoadInt32(&s.idleWorkers)),
+		ClaimingPaused: atomic.LoadInt32(&s.claimingPaused) == 1,
+		Autoscaling:    s.autoscaling,
+		InFlight:       make([]InFlightJob, 0, len(s.inFlight)),
+	}
+	for _, job := range s.inFlight {
+		stats.InFlight = append(stats.InFlight, job)
+	}
+	sort.Slice(stats.InFlight, func(i, j int) bool {
+		return stats.InFlight[i].StartedAt.Before(stats.InFlight[j].StartedAt)
+	})
+	return stats
+}
+
+func (s *Service) ClaimStats() (requested, taken int64) {
+	return atomic.LoadInt64(&s.requestedJobs), atomic.LoadInt64(&s.takenJobs)
+}
+
+// Resize adds or removes workers, so the pool has exactly the given amount of them.
+// It suspends active autoscaling, see ResumeAutoscaling.
+func (s *Service) Resize(workersAmount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resize(workersAmount); err != nil {
+		return err
+	}
+	if s.autoscaling == AutoscalingStateActive {
+		s.autoscaling = AutoscalingStateSuspended
+		s.logger.Info("autoscaling is suspended by a manual resize")
+	}
+	return nil
+}
+
+func (s *Service) autoResize(workersAmount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.autoscaling != AutoscalingStateActive {
+		return errors.Errorf("autoscaling is %s", s.autoscaling)
+	}
+	return s.resize(workersAmount)
+}
+
+func (s *Service) resize(workersAmount int) error {
+	if workersAmount < 0 {
+		return errors.Errorf("invalid workers amount = %v", workersAmount)
+	}
+	if err := s.checkRunning(); err != nil {
+		return err
+	}
+	if diff := workersAmount - len(s.workers); diff > 0 {
+		s.addWorkers(diff)
+	} else if diff < 0 {
+		s.removeWorkers(-diff)
+	}
+	return nil
+}
+
+func (s *Service) ResumeAutoscaling() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.autoscaling {
+	case AutoscalingStateDisabled:
+		return ErrAutoscalingDisabled
+	case AutoscalingStateSuspended:
+		s.autoscaling = AutoscalingStateActive
+		s.logger.Info("autoscaling is resumed")
+	}
+	return nil
+}
+
+func (s *Service) setAutoscaling(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.autoscaling = AutoscalingStateDisabled
+	if enabled {
+		s.autoscaling = AutoscalingStateActive
+	}
+}
+
+func (s *Service) PauseClaiming() {
+	if atomic.CompareAndSwapInt32(&s.claimingPaused, 0, 1) {
+		s.logger.Info("claiming is paused")
+	}
+}
+
+func (s *Service) ResumeClaiming() {
+	if atomic.CompareAndSwapInt32(&s.claimingPaused, 1, 0) {
+		s.logger.Info("claiming is resumed")
+		s.wakeup.broadcast()
+	}
+}
+
+func (s *Service) AddWorkers(amount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkRunning(); err != nil {
+		return err
+	}
+	s.addWorkers(amount)
+	return nil
+}
+
+// Workers added before the start would do jobs without the feeder. It must be called under the mutex.
+func (s *Service) checkRunning() error {
+	if !s.started {
+		return errors.New("not started")
+	}
+	if s.stopped {
+		return errors.New("already stopped")
+	}
+	return nil
+}
+
+func (s *Service) addWorkers(amount int) {
+	for i := 0; i < amount; i++ {
+		w := &worker{stopChan: make(chan struct{})}
+		s.workers = append(s.workers, w)
+		s.stopWaitGroup.Add(1)
+		go func() {
+			defer s.stopWaitGroup.Done()
+			s.doUntilStop(w)
+		}()
+	}
+	s.logger.Info("workers are added", zap.Int("added", amount), zap.Int("workers", len(s.workers)))
+}
+
+func (s *Service) RemoveWorkers(amount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkRunning(); err != nil {
+		return err
+	}
+	if amount > len(s.workers) {
+		return errors.Errorf("current workers amount = %v is less than requested = %v to stop",
+			len(s.workers), amount)
+	}
+	s.removeWorkers(amount)
+	return nil
+}
+
+func (s *Service) removeWorkers(amount int) {
+	removed := s.workers[len(s.workers)-amount:]
+	s.workers = s.workers[:len(s.workers)-amount]
+	for i := range removed {
+		close(removed[i].stopChan)
+	}
+	s.logger.Info("workers are removed", zap.Int("removed", amount), zap.Int("workers", len(s.workers)))
+}
+
+// Stop stops taking new jobs and waits for in-flight jobs. Jobs which are not done
+// within the shutdown grace period get their contexts cancelled, and the ones which still
+// don't return are handed back to their queues explicitly.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	s.mu.Unlock()
+
+	// the feeder hands its buffered jobs back, so nothing new is started after this point
+	if s.stopBackground != nil {
+		s.stopBackground()
+		s.backgroundWaitGroup.Wait()
+	}
+	s.mu.Lock()
+	for i := range s.workers {
+		close(s.workers[i].stopChan)
+	}
+	s.workers = nil
+	s.mu.Unlock()
+
+	stoppedChan := make(chan struct{})
+	go func() {
+		s.stopWaitGroup.Wait()
+		close(stoppedChan)
+	}()
+
+	if s.wait(stoppedChan, s.shutdownGracePeriod) {
+		s.cancelJobs()
+		s.logger.Info("all jobs are done")
+		return
+	}
+	s.logger.Warn("shutdown grace period is over, cancel in-flight jobs",
+		zap.Duration("gracePeriod", s.shutdownGracePeriod), zap.Int("inFlight", s.Stats().Busy))
+	s.cancelJobs()
+	if s.wait(stoppedChan, cancelledJobsWait) {
+		return
+	}
+
+	s.mu.Lock()
+	jobs := make([]schedule.Job, 0, len(s.inFlight))
+	for _, job := range s.inFlight {
+		jobs = append(jobs, job.Job)
+	}
+	s.mu.Unlock()
+	s.logger.Warn("cancelled jobs haven't returned, hand them back", zap.Int("inFlight", len(jobs)))
+	s.handBack(jobs)
+}
+
+func (s *Service) wait(ch <-chan struct{}, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (s *Service) doUntilStop(w *worker) {
+	for {
+		atomic.AddInt32(&s.idleWorkers, 1)
+		select {
+		case s.idleChan <- struct{}{}:
+		default:
+		}
+
+		select {
+		case <-w.stopChan:
+			atomic.AddInt32(&s.idleWorkers, -1)
+			return
+		case job := <-s.jobsChan:
+			atomic.AddInt32(&s.idleWorkers, -1)
+			s.do(context.Background(), job)
+		}
+	}
+}
+
+func (s *Service) startJob(job schedule.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[job.ID] = InFlightJob{Job: job, StartedAt: time.Now()}
+}
+
+func (s *Service) finishJob(job schedule.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, job.ID)
+}
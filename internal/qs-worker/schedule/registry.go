@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+)
+
+type Handler interface {
+	Handle(ctx context.Context, job schedule.Job) error
+}
+
+type HandlerFunc func(ctx context.Context, job schedule.Job) error
+
+func (f HandlerFunc) Handle(ctx context.Context, job schedule.Job) error {
+	return f(ctx, job)
+}
+
+type prefixHandler struct {
+	prefix  string
+	handler Handler
+}
+
+// Registry maps job actions to handlers. Exact action matches win over prefix
+// matches, and among prefixes the longest one wins.
+type Registry struct {
+	mu             sync.RWMutex
+	handlers       map[string]Handler
+	prefixHandlers []prefixHandler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+func (r *Registry) Handle(action string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[action] = handler
+}
+
+func (r *Registry) HandleFunc(action string, handler func(ctx context.Context, job schedule.Job) error) {
+	r.Handle(action, HandlerFunc(handler))
+}
+
+func (r *Registry) HandlePrefix(prefix string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.prefixHandlers {
+		if r.prefixHandlers[i].prefix == prefix {
+			r.prefixHandlers[i].handler = handler
+			return
+		}
+	}
+	r.prefixHandlers = append(r.prefixHandlers, prefixHandler{prefix: prefix, handler: handler})
+	sort.SliceStable(r.prefixHandlers, func(i, j int) bool {
+		return len(r.prefixHandlers[i].prefix) > len(r.prefixHandlers[j].prefix)
+	})
+}
+
+func (r *Registry) HandlePrefixFunc(prefix string, handler func(ctx context.Context, job schedule.Job) error) {
+	r.HandlePrefix(prefix, HandlerFunc(handler))
+}
+
+func (r *Registry) Lookup(action string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if handler, ok := r.handlers[action]; ok {
+		return handler, true
+	}
+	for i := range r.prefixHandlers {
+		if strings.HasPrefix(action, r.prefixHandlers[i].prefix) {
+			return r.prefixHandlers[i].handler, true
+		}
+	}
+	return nil, false
+}
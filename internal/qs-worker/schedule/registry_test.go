@@ -0,0 +1,46 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+)
+
+type namedHandler string
+
+func (namedHandler) Handle(context.Context, schedule.Job) error {
+	return nil
+}
+
+func TestRegistryLookup(t *testing.T) {
+	registry := NewRegistry()
+	registry.Handle("mail.send", namedHandler("exact"))
+	registry.HandlePrefix("mail.", namedHandler("mail"))
+	registry.HandlePrefix("mail.bulk.", namedHandler("bulk"))
+	registry.HandlePrefix("sms.", namedHandler("sms"))
+	registry.HandlePrefix("sms.", namedHandler("sms replaced"))
+
+	for _, tc := range []struct {
+		action string
+		want   namedHandler
+		wantOK bool
+	}{
+		{action: "mail.send", want: "exact", wantOK: true},
+		{action: "mail.receive", want: "mail", wantOK: true},
+		{action: "mail.bulk.send", want: "bulk", wantOK: true},
+		{action: "sms.send", want: "sms replaced", wantOK: true},
+		{action: "mail", wantOK: false},
+		{action: "push.send", wantOK: false},
+	} {
+		t.Run(tc.action, func(t *testing.T) {
+			handler, ok := registry.Lookup(tc.action)
+			if ok != tc.wantOK {
+				t.Fatalf("lookup found = %v, want %v", ok, tc.wantOK)
+			}
+			if ok && handler != tc.want {
+				t.Errorf("lookup = %v, want %v", handler, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"github.com/pkg/errors"
+)
+
+type RetryPolicyKind = string
+
+const (
+	RetryPolicyKindExponential = RetryPolicyKind("exponential")
+	RetryPolicyKindFixed       = RetryPolicyKind("fixed")
+)
+
+const (
+	defaultRetryBaseDelay = 10 * time.Second
+	defaultRetryMaxDelay  = 1 * time.Hour
+)
+
+type RetryPolicy interface {
+	NextDelay(attempt int, err error) time.Duration
+}
+
+type RetryPolicyFunc func(attempt int, err error) time.Duration
+
+func (f RetryPolicyFunc) NextDelay(attempt int, err error) time.Duration {
+	return f(attempt, err)
+}
+
+type FixedDelay struct {
+	Delay time.Duration
+}
+
+func (p FixedDelay) NextDelay(int, error) time.Duration {
+	return p.Delay
+}
+
+// ExponentialBackoff doubles the delay on every attempt up to MaxDelay and
+// randomizes the upper half of it so that failed jobs do not retry in lockstep.
+type ExponentialBackoff struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (p ExponentialBackoff) NextDelay(attempt int, _ error) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 1 {
+		attempt = 1
+	}
+	if shift := attempt - 1; shift < 32 {
+		if d := p.BaseDelay << shift; d > 0 && d < p.MaxDelay {
+			delay = d
+		}
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func NewRetryPolicy(kind RetryPolicyKind, baseDelay, maxDelay time.Duration) (RetryPolicy, error) {
+	if baseDelay == 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+	if maxDelay == 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+	switch kind {
+	case RetryPolicyKindExponential, "":
+		return ExponentialBackoff{BaseDelay: baseDelay, MaxDelay: maxDelay}, nil
+	case RetryPolicyKindFixed:
+		return FixedDelay{Delay: baseDelay}, nil
+	default:
+		return nil, errors.Errorf("unsupported retry policy = %q", kind)
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a handler error as non-retryable, so the job goes straight into the dead state.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanentErr *permanentError
+	return errors.As(err, &permanentErr)
+}
+
+var ErrJobTimeout = errors.New("job timeout")
+
+type timeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timeout: job hasn't been done within %s: %v", e.timeout, e.err)
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
+func (e *timeoutError) Is(target error) bool {
+	return target == ErrJobTimeout
+}
+
+func errorKind(err error) schedule.JobErrorKind {
+	if errors.Is(err, ErrJobTimeout) {
+		return schedule.JobErrorKindTimeout
+	}
+	return schedule.JobErrorKindError
+}
@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	p := ExponentialBackoff{BaseDelay: time.Second, MaxDelay: time.Minute}
+	for _, tc := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 4, want: 8 * time.Second},
+		{attempt: 6, want: 32 * time.Second},
+		{attempt: 7, want: time.Minute},
+		{attempt: 100, want: time.Minute},
+	} {
+		// jitter keeps the delay within the upper half of the exponential one
+		for i := 0; i < 100; i++ {
+			if delay := p.NextDelay(tc.attempt, nil); delay < tc.want/2 || delay > tc.want {
+				t.Fatalf("delay of attempt %d = %v, want within [%v, %v]", tc.attempt, delay, tc.want/2, tc.want)
+			}
+		}
+	}
+}
+
+func TestFixedDelay(t *testing.T) {
+	p := FixedDelay{Delay: time.Minute}
+	for _, attempt := range []int{1, 2, 10} {
+		if delay := p.NextDelay(attempt, nil); delay != time.Minute {
+			t.Errorf("delay of attempt %d = %v, want %v", attempt, delay, time.Minute)
+		}
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		kind    RetryPolicyKind
+		want    RetryPolicy
+		wantErr bool
+	}{
+		{kind: "", want: ExponentialBackoff{BaseDelay: time.Second, MaxDelay: defaultRetryMaxDelay}},
+		{kind: RetryPolicyKindExponential, want: ExponentialBackoff{BaseDelay: time.Second, MaxDelay: defaultRetryMaxDelay}},
+		{kind: RetryPolicyKindFixed, want: FixedDelay{Delay: time.Second}},
+		{kind: "linear", wantErr: true},
+	} {
+		policy, err := NewRetryPolicy(tc.kind, time.Second, 0)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("new retry policy %q: err = %v, want error = %v", tc.kind, err, tc.wantErr)
+		}
+		if policy != tc.want {
+			t.Errorf("new retry policy %q = %#v, want %#v", tc.kind, policy, tc.want)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	err := errors.New("bad payload")
+	if Permanent(nil) != nil {
+		t.Error("permanent nil error isn't nil")
+	}
+	if IsPermanent(err) {
+		t.Error("plain error is permanent")
+	}
+	wrapped := errors.Wrap(Permanent(err), "handle job")
+	if !IsPermanent(wrapped) {
+		t.Error("wrapped permanent error isn't permanent")
+	}
+	if !errors.Is(wrapped, err) {
+		t.Error("permanent error doesn't unwrap to its cause")
+	}
+}
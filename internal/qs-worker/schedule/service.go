@@ -3,6 +3,7 @@ package schedule
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
@@ -10,104 +11,398 @@ import (
 	"go.uber.org/zap"
 )
 
-const defaultCheckDuration = 30 * time.Second
+const (
+	defaultCheckDuration = 30 * time.Second
+	defaultBatchSize     = 10
+	defaultPriorityAging = time.Minute
+
+	defaultUnknownActionDelay = time.Minute
+
+	defaultShutdownGracePeriod = 30 * time.Second
+	cancelledJobsWait          = 5 * time.Second
+)
+
+// UnknownActionPolicy is what a worker does with a job it has no handler for. By default the job is
+// logged and finished as before handlers, UnknownActionPolicyLeave leaves it for a worker which knows the action.
+type UnknownActionPolicy = string
+
+const (
+	UnknownActionPolicyFail  = UnknownActionPolicy("fail")
+	UnknownActionPolicySkip  = UnknownActionPolicy("skip")
+	UnknownActionPolicyLeave = UnknownActionPolicy("leave")
+)
+
+var errUnknownAction = errors.New("unknown action")
 
 type scheduleStorage interface {
-	TakeJobIntoWork(ctx context.Context) (schedule.Job, error)
+	TakeJobsIntoWork(ctx context.Context, workerID string, n int, priorityAging time.Duration) ([]schedule.Job, error)
 	FinishJob(ctx context.Context, job schedule.Job) error
+	RenewJob(ctx context.Context, job schedule.Job) error
+	PostponeJob(ctx context.Context, job schedule.Job, dateTime time.Time) error
+	RetryJob(ctx context.Context, job schedule.Job, dateTime time.Time, kind schedule.JobErrorKind, reason string) error
+	FailJob(ctx context.Context, job schedule.Job, kind schedule.JobErrorKind, reason string) error
+	HeartBeatJob(ctx context.Context, job schedule.Job) error
+	ListenJobs(ctx context.Context, notify func(dateTime time.Time)) error
+	GetNextJobDateTime(ctx context.Context) (*time.Time, error)
+}
+
+type Config struct {
+	WorkerID             string
+	BatchSize            int
+	CheckDuration        time.Duration
+	FallbackPollDuration time.Duration
+	HeartBeatDuration    time.Duration
+	UnknownActionPolicy  UnknownActionPolicy
+	UnknownActionDelay   time.Duration
+	RetryPolicy          RetryPolicy
+	// PriorityAging is how long a job has to be overdue to gain one priority level,
+	// nil means the default of one minute and zero disables aging.
+	PriorityAging *time.Duration
+	// JobTimeout bounds jobs which have no timeout of their own or of their queue, zero means no timeout.
+	JobTimeout          time.Duration
+	ShutdownGracePeriod time.Duration
 }
 
 type Service struct {
 	logger          *zap.Logger
 	scheduleStorage scheduleStorage
+	registry        *Registry
+
+	workerID             string
+	batchSize            int
+	priorityAging        time.Duration
+	checkDuration        time.Duration
+	fallbackPollDuration time.Duration
+	heartBeatDuration    time.Duration
+	unknownActionPolicy  UnknownActionPolicy
+	unknownActionDelay   time.Duration
+	retryPolicy          RetryPolicy
+	jobTimeout           time.Duration
+	shutdownGracePeriod  time.Duration
+
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
 
-	checkDuration time.Duration
+	wakeup   *wakeup
+	jobsChan chan schedule.Job
+	idleChan chan struct{}
+	// idleWorkers is the amount of workers waiting for a job, it bounds the size of claimed batches.
+	idleWorkers    int32
+	claimingPaused int32
+	requestedJobs  int64
+	takenJobs      int64
 
-	currentWorkers int
-	doneChan       chan struct{}
-	stopWaitGroup  sync.WaitGroup
+	mu          sync.Mutex
+	started     bool
+	stopped     bool
+	autoscaling AutoscalingState
+	workers     []*worker
+	inFlight    map[int64]InFlightJob
+
+	stopBackground      context.CancelFunc
+	backgroundWaitGroup sync.WaitGroup
+	stopWaitGroup       sync.WaitGroup
 }
 
-func NewService(logger *zap.Logger, scheduleStorage scheduleStorage, checkDuration time.Duration) *Service {
-	if checkDuration == 0 {
-		checkDuration = defaultCheckDuration
+func NewService(logger *zap.Logger, scheduleStorage scheduleStorage, registry *Registry, cfg Config) *Service {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	priorityAging := defaultPriorityAging
+	if cfg.PriorityAging != nil {
+		priorityAging = *cfg.PriorityAging
+	}
+	if cfg.CheckDuration == 0 {
+		cfg.CheckDuration = defaultCheckDuration
+	}
+	if cfg.FallbackPollDuration == 0 {
+		cfg.FallbackPollDuration = defaultFallbackPollDuration
+	}
+	if cfg.HeartBeatDuration == 0 {
+		cfg.HeartBeatDuration = defaultHeartBeatDuration
 	}
-	return &Service{
-		logger:          logger,
-		scheduleStorage: scheduleStorage,
-		checkDuration:   checkDuration,
-		doneChan:        make(chan struct{}),
+	if cfg.UnknownActionPolicy == "" {
+		cfg.UnknownActionPolicy = UnknownActionPolicySkip
 	}
+	if cfg.UnknownActionDelay == 0 {
+		cfg.UnknownActionDelay = defaultUnknownActionDelay
+	}
+	if cfg.RetryPolicy == nil {
+		cfg.RetryPolicy = ExponentialBackoff{BaseDelay: defaultRetryBaseDelay, MaxDelay: defaultRetryMaxDelay}
+	}
+	if cfg.ShutdownGracePeriod == 0 {
+		cfg.ShutdownGracePeriod = defaultShutdownGracePeriod
+	}
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	s := &Service{
+		logger:               logger,
+		scheduleStorage:      scheduleStorage,
+		registry:             registry,
+		workerID:             cfg.WorkerID,
+		batchSize:            cfg.BatchSize,
+		priorityAging:        priorityAging,
+		checkDuration:        cfg.CheckDuration,
+		fallbackPollDuration: cfg.FallbackPollDuration,
+		heartBeatDuration:    cfg.HeartBeatDuration,
+		unknownActionPolicy:  cfg.UnknownActionPolicy,
+		unknownActionDelay:   cfg.UnknownActionDelay,
+		retryPolicy:          cfg.RetryPolicy,
+		jobTimeout:           cfg.JobTimeout,
+		shutdownGracePeriod:  cfg.ShutdownGracePeriod,
+		jobsCtx:              jobsCtx,
+		cancelJobs:           cancelJobs,
+		jobsChan:             make(chan schedule.Job),
+		idleChan:             make(chan struct{}, 1),
+		inFlight:             make(map[int64]InFlightJob),
+		autoscaling:          AutoscalingStateDisabled,
+	}
+	s.wakeup = newWakeup(func() { s.refreshNextJobDateTime(context.Background()) })
+	return s
 }
 
 func (s *Service) Start(workersAmount int) error {
-	if s.currentWorkers > 0 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
 		return errors.New("already started")
 	}
-	s.AddWorkers(workersAmount)
-	return nil
-}
-
-func (s *Service) AddWorkers(amount int) {
-	for i := 0; i < amount; i++ {
-		s.currentWorkers++
-		s.stopWaitGroup.Add(1)
-		go func() {
-			defer s.stopWaitGroup.Done()
-			s.doUntilStop()
-		}()
-	}
-}
-
-func (s *Service) RemoveWorkers(amount int) error {
-	if amount > s.currentWorkers {
-		return errors.Errorf("current workers amount = %v is less than requested = %v to stop",
-			s.currentWorkers, amount)
+	switch s.unknownActionPolicy {
+	case UnknownActionPolicyFail, UnknownActionPolicySkip, UnknownActionPolicyLeave:
+	default:
+		return errors.Errorf("unsupported unknown action policy = %q", s.unknownActionPolicy)
 	}
-	for i := 0; i < amount; i++ {
-		s.doneChan <- struct{}{}
+	// a job must get at least two heart beats within the shortest lease, otherwise it may be recovered while alive
+	if s.heartBeatDuration > schedule.MinLeaseDuration/2 {
+		return errors.Errorf("heart beat duration = %s is more than half of the min lease duration = %s",
+			s.heartBeatDuration, schedule.MinLeaseDuration)
 	}
-	return nil
-}
 
-func (s *Service) Stop() {
-	close(s.doneChan)
-	s.stopWaitGroup.Wait()
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	s.stopBackground = stopBackground
+	s.backgroundWaitGroup.Add(2)
+	go func() {
+		defer s.backgroundWaitGroup.Done()
+		s.listenUntilStop(backgroundCtx)
+	}()
+	go func() {
+		defer s.backgroundWaitGroup.Done()
+		s.feedUntilStop(backgroundCtx)
+	}()
+
+	s.started = true
+	s.addWorkers(workersAmount)
+	return nil
 }
 
-func (s *Service) doUntilStop() {
+// Once there are no due jobs left, the feeder waits for a wakeup. The ticker is a safety net
+// in case a notification gets lost.
+func (s *Service) feedUntilStop(ctx context.Context) {
 	ticker := time.NewTicker(s.checkDuration)
 	defer ticker.Stop()
 
 	for {
+		if atomic.LoadInt32(&s.claimingPaused) == 1 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wakeup.C():
+			}
+			continue
+		}
+
+		n := int(atomic.LoadInt32(&s.idleWorkers))
+		if n > s.batchSize {
+			n = s.batchSize
+		}
+		if n == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.idleChan:
+			}
+			continue
+		}
+
+		wakeup := s.wakeup.C()
+		jobs, err := s.scheduleStorage.TakeJobsIntoWork(context.Background(), s.workerID, n, s.priorityAging)
+		if err != nil {
+			s.logger.Error("failed to take jobs into work", zap.Error(err))
+		}
+		observeTakenJobs(jobs, time.Now())
+		atomic.AddInt64(&s.requestedJobs, int64(n))
+		atomic.AddInt64(&s.takenJobs, int64(len(jobs)))
+		if !s.feed(ctx, jobs) {
+			return
+		}
+		if len(jobs) == n {
+			continue
+		}
+		// due jobs of rate limited queues may be left, wake up when the queues get new tokens
+		s.refreshNextJobDateTime(ctx)
+
 		select {
-		case <-s.doneChan:
+		case <-ctx.Done():
 			return
+		case <-wakeup:
 		case <-ticker.C:
-			s.do()
 		}
 	}
 }
 
-func (s *Service) do() {
-	ctx := context.Background()
-	job, err := s.scheduleStorage.TakeJobIntoWork(ctx)
-	if errors.Is(err, schedule.ErrNoAvailableJobs) {
+// feed hands the buffered jobs over to the workers. Workers may be removed after the claim,
+// so the jobs which are not taken by a worker within a heart beat duration are handed back
+// to the queue before their leases expire. If the service is stopped meanwhile,
+// the rest of the jobs are handed back as well and false is returned.
+func (s *Service) feed(ctx context.Context, buffer []schedule.Job) bool {
+	timer := time.NewTimer(s.heartBeatDuration)
+	defer timer.Stop()
+
+	for i := range buffer {
+		select {
+		case s.jobsChan <- buffer[i]:
+		case <-timer.C:
+			s.logger.Warn("no idle workers for claimed jobs, hand them back", zap.Int("jobs", len(buffer)-i))
+			s.handBack(buffer[i:])
+			return true
+		case <-ctx.Done():
+			s.handBack(buffer[i:])
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Service) handBack(jobs []schedule.Job) {
+	for i := range jobs {
+		if err := s.scheduleStorage.RenewJob(context.Background(), jobs[i]); err != nil {
+			s.logTransitionError("failed to hand job back", jobs[i], err)
+		}
+	}
+}
+
+func (s *Service) do(ctx context.Context, job schedule.Job) {
+	s.startJob(job)
+	defer s.finishJob(job)
+
+	handler, ok := s.registry.Lookup(job.Action)
+	if !ok {
+		s.handleUnknownAction(ctx, job)
 		return
 	}
-	if err != nil {
-		s.logger.Error("failed to take job into work", zap.Error(err))
+
+	// the job may have waited for a worker, make sure it's still ours before doing it
+	if err := s.scheduleStorage.HeartBeatJob(ctx, job); errors.Is(err, schedule.ErrLeaseLost) {
+		s.logger.Warn("job lease is lost before doing job, leave it to the new owner", zap.Int64("jobID", job.ID))
 		return
+	} else if err != nil {
+		s.logger.Error("failed to heart beat job", zap.Int64("jobID", job.ID), zap.Error(err))
 	}
 
-	s.doJob(job)
+	timeout := s.timeout(job)
+	var (
+		jobCtx context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		jobCtx, cancel = context.WithTimeout(s.jobsCtx, timeout)
+	} else {
+		jobCtx, cancel = context.WithCancel(s.jobsCtx)
+	}
+	heartBeat := s.startHeartBeat(ctx, job, cancel)
+	err := s.doJob(jobCtx, handler, job)
+	leaseLost := heartBeat.stop()
+	jobCtxErr := jobCtx.Err()
+	cancel()
+
+	if leaseLost {
+		s.logger.Warn("job lease has been lost while doing job, leave it to the new owner",
+			zap.Int64("jobID", job.ID), zap.Error(err))
+		return
+	}
+	if err != nil && errors.Is(jobCtxErr, context.DeadlineExceeded) {
+		err = &timeoutError{timeout: timeout, err: err}
+	} else if err != nil && s.jobsCtx.Err() != nil {
+		s.logger.Warn("job has been cancelled on shutdown, hand it back",
+			zap.Int64("jobID", job.ID), zap.Error(err))
+		s.handBack([]schedule.Job{job})
+		return
+	}
+	if err != nil {
+		s.handleJobError(ctx, job, err)
+		return
+	}
 
 	if err = s.scheduleStorage.FinishJob(ctx, job); err != nil {
-		s.logger.Error("failed to finish job", zap.Error(err))
+		s.logTransitionError("failed to finish job", job, err)
+		return
+	}
+}
+
+func (s *Service) timeout(job schedule.Job) time.Duration {
+	switch {
+	case job.Timeout != nil:
+		return *job.Timeout
+	case job.Queue.JobTimeout != nil:
+		return *job.Queue.JobTimeout
+	default:
+		return s.jobTimeout
+	}
+}
+
+func (s *Service) doJob(ctx context.Context, handler Handler, job schedule.Job) error {
+	if err := handler.Handle(ctx, job); err != nil {
+		return errors.Wrap(err, "handle job")
+	}
+	s.logger.Info("job is done", zap.Int64("jobID", job.ID), zap.String("action", job.Action))
+	return nil
+}
+
+func (s *Service) handleUnknownAction(ctx context.Context, job schedule.Job) {
+	switch s.unknownActionPolicy {
+	case UnknownActionPolicySkip:
+		s.logger.Warn("skip job with unknown action", zap.Int64("jobID", job.ID), zap.String("action", job.Action))
+		if err := s.scheduleStorage.FinishJob(ctx, job); err != nil {
+			s.logTransitionError("failed to finish job", job, err)
+		}
+	case UnknownActionPolicyLeave:
+		// postponing keeps this worker from taking the same job again right away
+		s.logger.Debug("leave job with unknown action", zap.Int64("jobID", job.ID), zap.String("action", job.Action),
+			zap.Duration("delay", s.unknownActionDelay))
+		if err := s.scheduleStorage.PostponeJob(ctx, job, time.Now().Add(s.unknownActionDelay)); err != nil {
+			s.logTransitionError("failed to postpone job", job, err)
+		}
+	default:
+		s.handleJobError(ctx, job, Permanent(errUnknownAction))
+	}
+}
+
+func (s *Service) handleJobError(ctx context.Context, job schedule.Job, jobErr error) {
+	logger := s.logger.With(zap.Int64("jobID", job.ID), zap.String("action", job.Action),
+		zap.Int("attempt", job.Attempts+1), zap.Int("maxAttempts", job.MaxAttempts))
+	kind := errorKind(jobErr)
+
+	if IsPermanent(jobErr) || job.Attempts+1 >= job.MaxAttempts {
+		logger.Error("failed to do job, move it to dead jobs", zap.Error(jobErr))
+		if err := s.scheduleStorage.FailJob(ctx, job, kind, jobErr.Error()); err != nil {
+			s.logTransitionError("failed to fail job", job, err)
+		}
 		return
 	}
+
+	delay := s.retryPolicy.NextDelay(job.Attempts+1, jobErr)
+	logger.Warn("failed to do job, retry later", zap.Duration("delay", delay), zap.Error(jobErr))
+	if err := s.scheduleStorage.RetryJob(ctx, job, time.Now().Add(delay), kind, jobErr.Error()); err != nil {
+		s.logTransitionError("failed to retry job", job, err)
+	}
 }
 
-func (s *Service) doJob(job schedule.Job) {
-	s.logger.Info("job is done", zap.String("action", job.Action))
+// logTransitionError reports a failed job transition. A lost lease means the job has been renewed
+// and possibly taken by another worker meanwhile, so the stale result is dropped rather than failed.
+func (s *Service) logTransitionError(msg string, job schedule.Job, err error) {
+	if errors.Is(err, schedule.ErrLeaseLost) {
+		s.logger.Warn("job lease is lost, stale result is discarded", zap.Int64("jobID", job.ID),
+			zap.Int64("leaseToken", job.LeaseToken), zap.Error(err))
+		return
+	}
+	s.logger.Error(msg, zap.Int64("jobID", job.ID), zap.Error(err))
 }
@@ -0,0 +1,97 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SwirlGit/queue-scheduler/internal/pkg/schedule"
+	"go.uber.org/zap"
+)
+
+type fakeStorage struct {
+	scheduleStorage
+
+	mu          sync.Mutex
+	renewed     []int64
+	finished    []int64
+	heartBeatFn func(job schedule.Job) error
+}
+
+func (f *fakeStorage) RenewJob(_ context.Context, job schedule.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.renewed = append(f.renewed, job.ID)
+	return nil
+}
+
+func (f *fakeStorage) FinishJob(_ context.Context, job schedule.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.finished = append(f.finished, job.ID)
+	return nil
+}
+
+func (f *fakeStorage) HeartBeatJob(_ context.Context, job schedule.Job) error {
+	if f.heartBeatFn == nil {
+		return nil
+	}
+	return f.heartBeatFn(job)
+}
+
+func TestFeedHandsBackJobsWithoutIdleWorkers(t *testing.T) {
+	storage := &fakeStorage{}
+	s := NewService(zap.NewNop(), storage, NewRegistry(), Config{HeartBeatDuration: 10 * time.Millisecond})
+
+	// nobody receives from the jobs channel, as if all workers had been removed after the claim
+	if !s.feed(context.Background(), []schedule.Job{{ID: 1}, {ID: 2}}) {
+		t.Fatal("feed reported the service is stopped")
+	}
+	if len(storage.renewed) != 2 {
+		t.Errorf("handed back jobs %v, want both buffered jobs", storage.renewed)
+	}
+}
+
+func TestDoSkipsJobWithLostLease(t *testing.T) {
+	storage := &fakeStorage{heartBeatFn: func(schedule.Job) error { return schedule.ErrLeaseLost }}
+	registry := NewRegistry()
+	var handled bool
+	registry.HandleFunc("test", func(context.Context, schedule.Job) error {
+		handled = true
+		return nil
+	})
+	s := NewService(zap.NewNop(), storage, registry, Config{})
+
+	s.do(context.Background(), schedule.Job{ID: 1, Action: "test"})
+	if handled {
+		t.Error("job with a lost lease has been handled")
+	}
+	if len(storage.finished) != 0 || len(storage.renewed) != 0 {
+		t.Errorf("job with a lost lease has been transitioned: finished %v, renewed %v",
+			storage.finished, storage.renewed)
+	}
+}
+
+func TestResizeRequiresStartedService(t *testing.T) {
+	s := NewService(zap.NewNop(), &fakeStorage{}, NewRegistry(), Config{})
+
+	if err := s.Resize(2); err == nil {
+		t.Error("resize of a service which isn't started succeeded")
+	}
+	if err := s.AddWorkers(2); err == nil {
+		t.Error("adding workers to a service which isn't started succeeded")
+	}
+	if workers := s.Stats().Workers; workers != 0 {
+		t.Errorf("service which isn't started has %d workers", workers)
+	}
+}
+
+func TestStartRejectsHeartBeatLongerThanHalfOfMinLease(t *testing.T) {
+	s := NewService(zap.NewNop(), &fakeStorage{}, NewRegistry(),
+		Config{HeartBeatDuration: schedule.MinLeaseDuration/2 + time.Second})
+	if err := s.Start(1); err == nil {
+		s.Stop()
+		t.Error("service with a heart beat longer than half of the min lease started")
+	}
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+)
+
+type wakeup struct {
+	mu      sync.Mutex
+	ch      chan struct{}
+	timer   *time.Timer
+	timerAt time.Time
+	onTimer func()
+}
+
+func newWakeup(onTimer func()) *wakeup {
+	return &wakeup{
+		ch:      make(chan struct{}),
+		onTimer: onTimer,
+	}
+}
+
+func (w *wakeup) C() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ch
+}
+
+func (w *wakeup) broadcast() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	close(w.ch)
+	w.ch = make(chan struct{})
+}
+
+// at broadcasts at the given time, or right away if it has already come.
+// Only the earliest pending time is kept.
+func (w *wakeup) at(t time.Time) {
+	delay := time.Until(t)
+	if delay <= 0 {
+		w.broadcast()
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil && !w.timerAt.After(t) {
+		return
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timerAt = t
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		// a timer which has fired while being replaced or stopped must not clear its successor
+		w.mu.Lock()
+		if w.timer != timer {
+			w.mu.Unlock()
+			return
+		}
+		w.timer = nil
+		w.mu.Unlock()
+
+		w.broadcast()
+		if w.onTimer != nil {
+			w.onTimer()
+		}
+	})
+	w.timer = timer
+}
+
+func (w *wakeup) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
@@ -0,0 +1,93 @@
+package schedule
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitWakeup(t *testing.T, ch <-chan struct{}, within time.Duration) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(within):
+		t.Fatalf("no wakeup within %v", within)
+	}
+}
+
+func TestWakeupEarlierTimeReplacesPendingTimer(t *testing.T) {
+	var fired int32
+	w := newWakeup(func() { atomic.AddInt32(&fired, 1) })
+	defer w.stop()
+
+	ch := w.C()
+	w.at(time.Now().Add(time.Hour))
+	w.at(time.Now().Add(20 * time.Millisecond))
+	waitWakeup(t, ch, time.Second)
+
+	// the replaced timer of an hour must not fire later on top of the earlier one
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&fired); n != 1 {
+		t.Errorf("timer fired %d times, want 1", n)
+	}
+}
+
+func TestWakeupKeepsEarlierPendingTimer(t *testing.T) {
+	w := newWakeup(nil)
+	defer w.stop()
+
+	ch := w.C()
+	w.at(time.Now().Add(20 * time.Millisecond))
+	w.at(time.Now().Add(time.Hour))
+	waitWakeup(t, ch, time.Second)
+}
+
+func TestWakeupStaleTimerDoesNotFire(t *testing.T) {
+	var fired int32
+	w := newWakeup(func() { atomic.AddInt32(&fired, 1) })
+	defer w.stop()
+
+	ch := w.C()
+	w.at(time.Now().Add(10 * time.Millisecond))
+	// the timer fires while at replaces it
+	w.mu.Lock()
+	time.Sleep(30 * time.Millisecond)
+	successor := time.AfterFunc(time.Hour, func() {})
+	w.timer, w.timerAt = successor, time.Now().Add(time.Hour)
+	w.mu.Unlock()
+
+	select {
+	case <-ch:
+		t.Error("stale timer woke up the feeder")
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n := atomic.LoadInt32(&fired); n != 0 || w.timer != successor {
+		t.Errorf("stale timer fired %d times, successor kept = %v", n, w.timer == successor)
+	}
+}
+
+func TestWakeupStopCancelsTimer(t *testing.T) {
+	var fired int32
+	w := newWakeup(func() { atomic.AddInt32(&fired, 1) })
+
+	ch := w.C()
+	w.at(time.Now().Add(10 * time.Millisecond))
+	w.stop()
+	select {
+	case <-ch:
+		t.Error("stopped timer woke up the feeder")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := atomic.LoadInt32(&fired); n != 0 {
+		t.Errorf("stopped timer fired %d times", n)
+	}
+}
+
+func TestWakeupPastTimeBroadcastsRightAway(t *testing.T) {
+	w := newWakeup(nil)
+	ch := w.C()
+	w.at(time.Now().Add(-time.Second))
+	waitWakeup(t, ch, 10*time.Millisecond)
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+type Handler struct {
+	handler fasthttp.RequestHandler
+}
+
+func NewHandler() *Handler {
+	return &Handler{handler: fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())}
+}
+
+func (h *Handler) RegisterFastHTTPRouters(a fiber.Router) {
+	a.Get("/metrics", func(c *fiber.Ctx) error {
+		h.handler(c.Context())
+		return nil
+	})
+}